@@ -0,0 +1,254 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/chauvinhphuoc/snippetbox/internal/db/sqlc"
+	"net/http"
+	"strconv"
+)
+
+// The scopes which an API token can grant.
+const (
+	scopeSnippetsRead  = "snippets:read"
+	scopeSnippetsWrite = "snippets:write"
+)
+
+// apiScope describes a scope in the API token form.
+type apiScope struct {
+	Name  string
+	Label string
+}
+
+func (s apiScope) optionName() string { return s.Name }
+
+// apiScopes lists the scopes offered by the API token form, in the order they are shown.
+var apiScopes = []apiScope{
+	{scopeSnippetsRead, "Read snippets"},
+	{scopeSnippetsWrite, "Create, update and delete your snippets"},
+}
+
+// apiDefaultPageSize and apiMaxPageSize bound the "limit" query parameter of the snippet list.
+const (
+	apiDefaultPageSize = 20
+	apiMaxPageSize     = 100
+)
+
+// apiCursors holds the cursors of the pages around a page of the snippet list,
+// to pass as "?before=" (older) and "?after=" (newer). They are left out when
+// there is no such page.
+type apiCursors struct {
+	Older int32 `json:"older,omitempty"`
+	Newer int32 `json:"newer,omitempty"`
+}
+
+// GET /api/v1/snippets
+// The list is paginated like the home page, with "?before=ID" or "?after=ID",
+// and "?limit=" sets the number of snippets per page.
+func (app *application) apiListSnippets(w http.ResponseWriter, r *http.Request) {
+	before, err := parseCursor(r, "before")
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, "The before parameter must be a snippet ID")
+		return
+	}
+
+	after, err := parseCursor(r, "after")
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, "The after parameter must be a snippet ID")
+		return
+	}
+
+	if before != 0 && after != 0 {
+		app.apiError(w, http.StatusBadRequest, "The before and after parameters cannot be used together")
+		return
+	}
+
+	pageSize := apiDefaultPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		pageSize, err = strconv.Atoi(value)
+		if err != nil || pageSize < 1 || pageSize > apiMaxPageSize {
+			app.apiError(w, http.StatusBadRequest, fmt.Sprintf("The limit parameter must be between 1 and %d", apiMaxPageSize))
+			return
+		}
+	}
+
+	snippets, cursor, err := app.listLiveSnippets(r.Context(), before, after, pageSize)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	// An empty page must be encoded as [] rather than null.
+	if snippets == nil {
+		snippets = []sqlc.ListSnippetsBeforeRow{}
+	}
+
+	// The content of a protected snippet is only listed once it is unlocked,
+	// and the one of a snippet deleted once read only to its owner.
+	for i, snippet := range snippets {
+		if app.isSnippetLocked(r, snippet.ID, snippet.UserID, snippet.HashedPassword) ||
+			app.mustRevealSnippet(r, snippet.UserID, snippet.BurnAfterReading) {
+			snippets[i].Content = ""
+		}
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"snippets": snippets,
+		"cursors":  apiCursors{Older: cursor.Older, Newer: cursor.Newer},
+	})
+}
+
+// GET /api/v1/snippets/:id
+func (app *application) apiGetSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiGetLiveSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"snippet": snippet})
+}
+
+// readSnippetInput decodes and validates the snippet of the request body, with
+// the same rules as the snippet forms. The language, the format, the visibility,
+// the expiry and its unit may be left out, then they get the same defaults as in
+// the create form, so "expires" alone is a number of days.
+func (app *application) readSnippetInput(w http.ResponseWriter, r *http.Request) (input createSnippetFormResult, ok bool) {
+	if !app.readJSON(w, r, &input) {
+		return input, false
+	}
+
+	if input.Language == "" {
+		input.Language = defaultLanguage
+	}
+	if input.Format == "" {
+		input.Format = defaultFormat
+	}
+	if input.Visibility == "" {
+		input.Visibility = defaultVisibility
+	}
+	if input.Expiry == "" {
+		input.Expiry = expiryAfter
+	}
+	if input.ExpiresUnit == "" {
+		input.ExpiresUnit = defaultExpiresUnit
+	}
+
+	input.CanNeverExpire = app.neverExpire.allows(app.authenticatedUserID(r))
+	input.validate()
+
+	if !input.IsNoErrors() {
+		app.apiValidationError(w, input.FieldErrors)
+		return input, false
+	}
+
+	return input, true
+}
+
+// POST /api/v1/snippets
+func (app *application) apiCreateSnippet(w http.ResponseWriter, r *http.Request) {
+	input, ok := app.readSnippetInput(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	slug, err := generateRandomToken(snippetSlugBytes)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	hashedPassword, err := app.snippetPasswordHash(input, sql.NullString{})
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	id, err := app.CreateSnippet(r.Context(), sqlc.CreateSnippetParams{
+		Title:            input.Title,
+		Content:          input.Content,
+		Expires:          input.expiresTime,
+		UserID:           int32(userID),
+		Language:         input.Language,
+		Format:           input.Format,
+		Visibility:       input.Visibility,
+		Slug:             slug,
+		HashedPassword:   hashedPassword,
+		BurnAfterReading: input.BurnAfterReading,
+	})
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippet, err := app.GetSnippetNotExpired(r.Context(), sqlc.GetSnippetNotExpiredParams{ID: id, ViewerID: int32(userID)})
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, http.StatusCreated, envelope{"snippet": snippet})
+}
+
+// PUT /api/v1/snippets/:id
+// Like the edit form, every field is replaced but the password, which is kept
+// unless a new one or remove_password is sent.
+func (app *application) apiUpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiGetOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	input, ok := app.readSnippetInput(w, r)
+	if !ok {
+		return
+	}
+
+	hashedPassword, err := app.snippetPasswordHash(input, snippet.HashedPassword)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.UpdateSnippet(r.Context(), sqlc.UpdateSnippetParams{
+		Title:            input.Title,
+		Content:          input.Content,
+		Language:         input.Language,
+		Format:           input.Format,
+		Visibility:       input.Visibility,
+		HashedPassword:   hashedPassword,
+		BurnAfterReading: input.BurnAfterReading,
+		Expires:          input.expiresTime,
+		ID:               snippet.ID,
+	})
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	updated, err := app.GetSnippetNotExpired(r.Context(), sqlc.GetSnippetNotExpiredParams{ID: snippet.ID, ViewerID: snippet.UserID})
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"snippet": updated})
+}
+
+// DELETE /api/v1/snippets/:id
+func (app *application) apiDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiGetOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.DeleteSnippet(r.Context(), snippet.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/chauvinhphuoc/snippetbox/internal/db/sqlc"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// envelope wraps every JSON response body, like {"snippet": {...}} or {"error": {...}}.
+type envelope map[string]any
+
+// apiErrorBody is the body of every error response of the API.
+type apiErrorBody struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"` // the validation error of each invalid field
+}
+
+// writeJSON writes data as the JSON body of the response with the status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// apiError writes a JSON error response, the message defaults to the status text.
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	app.writeJSON(w, status, envelope{"error": apiErrorBody{Status: status, Message: message}})
+}
+
+// apiServerError logs the error like serverError does, then writes a JSON 500 response.
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	app.errorLog.Output(2, err.Error())
+
+	status := http.StatusInternalServerError
+	body, _ := json.Marshal(envelope{"error": apiErrorBody{Status: status, Message: http.StatusText(status)}})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// apiValidationError writes a 422 JSON response listing the validation error of each field.
+func (app *application) apiValidationError(w http.ResponseWriter, fieldErrors map[string]string) {
+	status := http.StatusUnprocessableEntity
+
+	app.writeJSON(w, status, envelope{"error": apiErrorBody{
+		Status:  status,
+		Message: "The request body contains invalid fields",
+		Fields:  fieldErrors,
+	}})
+}
+
+// invalidTokenError writes a JSON 401 response for a request whose API token is
+// malformed, unknown or expired.
+func (app *application) invalidTokenError(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	app.apiError(w, http.StatusUnauthorized, "The API token is invalid or has expired")
+}
+
+// hashAPIToken returns the SHA-256 hash of an API token, which is what the database stores.
+func hashAPIToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// readJSON decodes the JSON body of the request into dst. The body must be a
+// single JSON value without unknown fields, sent with the application/json
+// content type.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		app.apiError(w, http.StatusUnsupportedMediaType, "The request body must be application/json")
+		return false
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil {
+		// Anything but the end of the body after the value is an error.
+		err = decoder.Decode(&struct{}{})
+		if err == io.EOF {
+			return true
+		}
+		if err == nil {
+			app.apiError(w, http.StatusBadRequest, "The body must only contain a single JSON value")
+			return false
+		}
+	}
+
+	var (
+		syntaxError        *json.SyntaxError
+		unmarshalTypeError *json.UnmarshalTypeError
+		maxBytesError      *http.MaxBytesError
+		message            string
+	)
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		app.apiError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes", maxBytesError.Limit))
+		return false
+	case errors.As(err, &syntaxError):
+		message = fmt.Sprintf("The body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		message = "The body contains badly-formed JSON"
+	case errors.As(err, &unmarshalTypeError):
+		message = fmt.Sprintf("The body contains an incorrect JSON type for the field %q", unmarshalTypeError.Field)
+	case errors.Is(err, io.EOF):
+		message = "The body must not be empty"
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		message = fmt.Sprintf("The body contains the unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		message = "The body could not be decoded: " + err.Error()
+	}
+
+	app.apiError(w, http.StatusBadRequest, message)
+	return false
+}
+
+// apiSnippetID returns the snippet ID in the URL path, or writes a JSON 404 response.
+func (app *application) apiSnippetID(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.apiError(w, http.StatusNotFound, "")
+		return 0, false
+	}
+
+	return int32(id), true
+}
+
+// apiGetLiveSnippet returns the snippet which has not expired whose ID is in
+// the URL path, private snippets only to their owner. Otherwise it writes a
+// JSON 404 response (403 when it is locked by a password or deleted once read,
+// or 500 on database error) and ok is false.
+func (app *application) apiGetLiveSnippet(w http.ResponseWriter, r *http.Request) (snippet sqlc.GetSnippetNotExpiredRow, ok bool) {
+	id, ok := app.apiSnippetID(w, r)
+	if !ok {
+		return snippet, false
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	snippet, err := app.GetSnippetNotExpired(r.Context(), sqlc.GetSnippetNotExpiredParams{ID: id, ViewerID: int32(userID)})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.apiError(w, http.StatusNotFound, "")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return snippet, false
+	}
+
+	// A password can only be given with the form of the snippet page.
+	if app.isSnippetLocked(r, snippet.ID, snippet.UserID, snippet.HashedPassword) {
+		app.apiError(w, http.StatusForbidden, "The snippet is protected by a password")
+		return snippet, false
+	}
+
+	// Reading would delete the snippet, which is only done by its reveal form.
+	if app.mustRevealSnippet(r, snippet.UserID, snippet.BurnAfterReading) {
+		app.apiError(w, http.StatusForbidden, "The snippet is deleted once read, reveal it on its page")
+		return snippet, false
+	}
+
+	return snippet, true
+}
+
+// apiGetOwnedSnippet is the JSON counterpart of getOwnedSnippet: it returns the
+// snippet whose ID is in the URL path if it belongs to the authenticated user,
+// expired or not. Otherwise it writes a JSON 404 or 403 response and ok is false.
+func (app *application) apiGetOwnedSnippet(w http.ResponseWriter, r *http.Request) (snippet sqlc.Snippet, ok bool) {
+	id, ok := app.apiSnippetID(w, r)
+	if !ok {
+		return snippet, false
+	}
+
+	snippet, err := app.GetSnippet(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.apiError(w, http.StatusNotFound, "")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return snippet, false
+	}
+
+	userID := app.authenticatedUserID(r)
+	if snippet.UserID != int32(userID) {
+		app.apiError(w, http.StatusForbidden, "The snippet belongs to another user")
+		return snippet, false
+	}
+
+	return snippet, true
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// envPrefix is prepended to the upper-cased flag name (with '-' replaced by '_')
+// to get the environment variable of a setting, e.g. -session-lifetime can be
+// set through SNIPPETBOX_SESSION_LIFETIME.
+const envPrefix = "SNIPPETBOX_"
+
+// defaultCSP allows the Google fonts loaded by base.html, and inline scripts
+// only when they carry the nonce of the request.
+const defaultCSP = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; " +
+	"script-src 'self' 'nonce-" + cspNoncePlaceholder + "'; object-src 'none'; base-uri 'self'; " +
+	"form-action 'self'; frame-ancestors 'none'"
+
+// config holds every setting of the application. The settings are loaded, in
+// order of increasing priority, from their defaults, an optional JSON config file,
+// environment variables and command-line flags.
+type config struct {
+	dev             bool
+	addr            string
+	shutdownTimeout time.Duration
+	server          struct {
+		readTimeout       time.Duration
+		readHeaderTimeout time.Duration
+		writeTimeout      time.Duration
+		idleTimeout       time.Duration
+		maxHeaderBytes    int
+		maxBodyBytes      int64
+	}
+	dsn         string
+	bcryptCost  int
+	neverExpire string
+	staticDir   string
+	templateDir string
+	headers     struct {
+		csp            string
+		referrerPolicy string
+		hstsMaxAge     time.Duration
+	}
+	tls struct {
+		certFile     string
+		keyFile      string
+		redirectAddr string
+	}
+	session struct {
+		lifetime       time.Duration
+		cookieName     string
+		cookieDomain   string
+		cookieSecure   bool
+		cookiePersist  bool
+		cookieSameSite string
+	}
+}
+
+// loadConfig parses the command-line arguments (without the program name) and
+// merges them with the config file and environment variables, then validates
+// the result.
+func loadConfig(args []string) (config, error) {
+	var cfg config
+
+	fs, configFile := newFlagSet(&cfg)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+
+	// Remember the flags given on the command line, they win over everything else.
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if *configFile == "" {
+		*configFile = os.Getenv(envName("config"))
+	}
+
+	if *configFile != "" {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return cfg, err
+		}
+
+		for name, value := range values {
+			if fs.Lookup(name) == nil || name == "config" {
+				return cfg, fmt.Errorf("config file %s: unknown setting %q", *configFile, name)
+			}
+			if explicit[name] {
+				continue
+			}
+
+			err = fs.Set(name, value)
+			if err != nil {
+				return cfg, fmt.Errorf("config file %s: invalid value %q for %q: %w", *configFile, value, name, err)
+			}
+		}
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == "config" {
+			return
+		}
+
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("environment variable %s: invalid value %q: %w", envName(f.Name), value, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	// Reloading the embedded templates would never show any change.
+	if cfg.dev && cfg.templateDir == "" {
+		cfg.templateDir = "./ui/html"
+	}
+
+	return cfg, cfg.validate()
+}
+
+// newFlagSet defines every setting as a flag which stores its value into cfg,
+// and sets cfg to the defaults. The returned string holds the -config flag.
+func newFlagSet(cfg *config) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("snippetbox", flag.ContinueOnError)
+
+	configFile := fs.String("config", "", "Path to an optional JSON config file, its keys are the flag names")
+	fs.BoolVar(&cfg.dev, "dev", false, "Development mode: reload templates on every request and show template errors in the browser")
+	fs.StringVar(&cfg.addr, "addr", "127.0.0.1:4000", "HTTP network address")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 30*time.Second, "Time given to in-flight requests to finish when shutting down")
+	fs.DurationVar(&cfg.server.readTimeout, "read-timeout", 5*time.Second, "Maximum time to read a whole request, including the body")
+	fs.DurationVar(&cfg.server.readHeaderTimeout, "read-header-timeout", 2*time.Second, "Maximum time to read the request headers")
+	fs.DurationVar(&cfg.server.writeTimeout, "write-timeout", 10*time.Second, "Maximum time to write a response")
+	fs.DurationVar(&cfg.server.idleTimeout, "idle-timeout", time.Minute, "Maximum time to keep an idle keep-alive connection open")
+	fs.IntVar(&cfg.server.maxHeaderBytes, "max-header-bytes", 64<<10, "Maximum size of the request headers in bytes")
+	fs.Int64Var(&cfg.server.maxBodyBytes, "max-body-bytes", 1<<20, "Maximum size of a request body in bytes")
+	fs.StringVar(&cfg.tls.certFile, "tls-cert", "", "TLS certificate file, serve HTTPS when it is set together with -tls-key")
+	fs.StringVar(&cfg.tls.keyFile, "tls-key", "", "TLS private key file")
+	fs.StringVar(&cfg.tls.redirectAddr, "http-redirect-addr", "", "Optional HTTP network address which redirects every request to HTTPS")
+	fs.StringVar(&cfg.dsn, "dsn", "postgres://postgres@localhost:5432/snippetbox?sslmode=disable", "PostgreSQL data source name")
+	fs.IntVar(&cfg.bcryptCost, "bcrypt-cost", 12, "Cost of the bcrypt hash of user passwords")
+	fs.StringVar(&cfg.neverExpire, "never-expire", "none", "Who may create snippets which never expire: none, all or a comma-separated list of user IDs")
+	fs.StringVar(&cfg.staticDir, "static-dir", "", "Serve the static files from this directory (e.g. ./ui/static) instead of the embedded ones")
+	fs.StringVar(&cfg.templateDir, "template-dir", "", "Load the HTML templates from this directory (e.g. ./ui/html) instead of the embedded ones")
+	fs.StringVar(&cfg.headers.csp, "csp", defaultCSP, "Content-Security-Policy header, "+cspNoncePlaceholder+" is replaced by a per-request nonce, empty disables it")
+	fs.StringVar(&cfg.headers.referrerPolicy, "referrer-policy", "origin-when-cross-origin", "Referrer-Policy header")
+	fs.DurationVar(&cfg.headers.hstsMaxAge, "hsts-max-age", 0, "Max age of the Strict-Transport-Security header sent over HTTPS, 0 disables it")
+	fs.DurationVar(&cfg.session.lifetime, "session-lifetime", 12*time.Hour, "Time after which a session expires")
+	fs.StringVar(&cfg.session.cookieName, "session-cookie-name", "session", "Name of the session cookie")
+	fs.StringVar(&cfg.session.cookieDomain, "session-cookie-domain", "", "Domain of the session cookie")
+	fs.BoolVar(&cfg.session.cookieSecure, "session-cookie-secure", false, "Only send the session cookie over HTTPS")
+	fs.BoolVar(&cfg.session.cookiePersist, "session-cookie-persist", true, "Keep the session cookie after the browser is closed")
+	fs.StringVar(&cfg.session.cookieSameSite, "session-cookie-samesite", "lax", "SameSite mode of the session cookie: lax, strict or none")
+
+	return fs, configFile
+}
+
+// envName returns the environment variable which holds the setting of a flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readConfigFile reads a JSON object of flag names and their values. The values
+// can be strings, numbers or booleans, they are all returned in their text form.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		switch value.(type) {
+		case string, float64, bool:
+			values[name] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("config file %s: value of %q must be a string, number or boolean", path, name)
+		}
+	}
+
+	return values, nil
+}
+
+// validate checks that all settings have usable values. It reports every
+// invalid setting at once instead of stopping at the first one.
+func (cfg config) validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(cfg.addr); err != nil {
+		errs = append(errs, fmt.Errorf("-addr %q must be in the form host:port", cfg.addr))
+	}
+
+	if cfg.shutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("-shutdown-timeout %s must be positive", cfg.shutdownTimeout))
+	}
+
+	timeouts := []struct {
+		flag  string
+		value time.Duration
+	}{
+		{"-read-timeout", cfg.server.readTimeout},
+		{"-read-header-timeout", cfg.server.readHeaderTimeout},
+		{"-write-timeout", cfg.server.writeTimeout},
+		{"-idle-timeout", cfg.server.idleTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s %s must be positive", timeout.flag, timeout.value))
+		}
+	}
+
+	if cfg.server.readHeaderTimeout > cfg.server.readTimeout {
+		errs = append(errs, errors.New("-read-header-timeout cannot be longer than -read-timeout"))
+	}
+
+	if cfg.server.maxHeaderBytes < 4<<10 {
+		errs = append(errs, fmt.Errorf("-max-header-bytes %d must be at least 4096", cfg.server.maxHeaderBytes))
+	}
+
+	if cfg.server.maxBodyBytes < 4<<10 {
+		errs = append(errs, fmt.Errorf("-max-body-bytes %d must be at least 4096", cfg.server.maxBodyBytes))
+	}
+
+	if (cfg.tls.certFile == "") != (cfg.tls.keyFile == "") {
+		errs = append(errs, errors.New("-tls-cert and -tls-key must be set together"))
+	}
+
+	for _, file := range []string{cfg.tls.certFile, cfg.tls.keyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			errs = append(errs, fmt.Errorf("TLS file: %w", err))
+		}
+	}
+
+	if cfg.tls.redirectAddr != "" {
+		if !cfg.useTLS() {
+			errs = append(errs, errors.New("-http-redirect-addr requires -tls-cert and -tls-key"))
+		}
+		if _, _, err := net.SplitHostPort(cfg.tls.redirectAddr); err != nil {
+			errs = append(errs, fmt.Errorf("-http-redirect-addr %q must be in the form host:port", cfg.tls.redirectAddr))
+		}
+	}
+
+	if strings.TrimSpace(cfg.dsn) == "" {
+		errs = append(errs, errors.New("-dsn cannot be blank"))
+	}
+
+	if cfg.bcryptCost < bcrypt.MinCost || cfg.bcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("-bcrypt-cost %d must be between %d and %d", cfg.bcryptCost, bcrypt.MinCost, bcrypt.MaxCost))
+	}
+
+	if _, err := parseNeverExpire(cfg.neverExpire); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.staticDir != "" {
+		if err := checkDir(cfg.staticDir); err != nil {
+			errs = append(errs, fmt.Errorf("-static-dir: %w", err))
+		}
+	}
+
+	if cfg.templateDir != "" {
+		if err := checkDir(cfg.templateDir); err != nil {
+			errs = append(errs, fmt.Errorf("-template-dir: %w", err))
+		}
+	}
+
+	if !validReferrerPolicies[cfg.headers.referrerPolicy] {
+		errs = append(errs, fmt.Errorf("-referrer-policy %q is not a valid policy", cfg.headers.referrerPolicy))
+	}
+
+	if cfg.headers.hstsMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("-hsts-max-age %s cannot be negative", cfg.headers.hstsMaxAge))
+	}
+
+	if cfg.session.lifetime <= 0 {
+		errs = append(errs, fmt.Errorf("-session-lifetime %s must be positive", cfg.session.lifetime))
+	}
+
+	if strings.TrimSpace(cfg.session.cookieName) == "" {
+		errs = append(errs, errors.New("-session-cookie-name cannot be blank"))
+	}
+
+	sameSite, err := parseSameSite(cfg.session.cookieSameSite)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// Browsers reject cookies with "SameSite=None" which are not also secure.
+	if sameSite == http.SameSiteNoneMode && !cfg.session.cookieSecure && !cfg.useTLS() {
+		errs = append(errs, errors.New("-session-cookie-samesite none requires -session-cookie-secure"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// useTLS reports whether the server is configured to serve HTTPS.
+func (cfg config) useTLS() bool {
+	return cfg.tls.certFile != "" && cfg.tls.keyFile != ""
+}
+
+// validReferrerPolicies are the values allowed in the Referrer-Policy header.
+var validReferrerPolicies = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
+}
+
+// checkDir returns an error if the path doesn't exist or isn't a directory.
+func checkDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	return nil
+}
+
+// parseSameSite converts the name of a SameSite mode into its http.SameSite value.
+func parseSameSite(mode string) (http.SameSite, error) {
+	switch strings.ToLower(mode) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return http.SameSiteDefaultMode, fmt.Errorf("-session-cookie-samesite %q must be lax, strict or none", mode)
+	}
+}
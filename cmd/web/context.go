@@ -2,4 +2,9 @@ package main
 
 type contextKey string
 
-const isAuthenticatedContextKey = contextKey("isAuthenticated")
+const (
+	isAuthenticatedContextKey = contextKey("isAuthenticated")
+	userIDContextKey          = contextKey("userID")
+	apiScopesContextKey       = contextKey("apiScopes")
+	cspNonceContextKey        = contextKey("cspNonce")
+)
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The expiries of a snippet, which are the values of the "expiry" field of the snippet forms.
+const (
+	expiryAfter = "after" // after a duration counted from now
+	expiryAt    = "at"    // at an exact date-time
+	expiryNever = "never" // never, for the users allowed by the -never-expire policy
+)
+
+// expiresUnit is a unit of the duration after which a snippet expires.
+type expiresUnit struct {
+	Name     string
+	Label    string
+	Duration time.Duration
+}
+
+// defaultExpiresUnit is the unit of the duration when it is left out of a JSON snippet.
+const defaultExpiresUnit = "days"
+
+// expiresUnits lists the units offered by the snippet forms, in the order they are shown.
+var expiresUnits = []expiresUnit{
+	{"minutes", "Minutes", time.Minute},
+	{"hours", "Hours", time.Hour},
+	{defaultExpiresUnit, "Days", 24 * time.Hour},
+}
+
+// findExpiresUnit returns the unit with the given name.
+func findExpiresUnit(name string) (expiresUnit, bool) {
+	for _, unit := range expiresUnits {
+		if unit.Name == name {
+			return unit, true
+		}
+	}
+
+	return expiresUnit{}, false
+}
+
+// maxExpiry is the longest time a snippet can be kept, unless it never expires.
+const maxExpiry = 365 * 24 * time.Hour
+
+// neverExpirePolicy tells which users may create snippets which never expire.
+type neverExpirePolicy struct {
+	all     bool
+	userIDs map[int]bool
+}
+
+// parseNeverExpire converts the -never-expire setting into its policy: "none",
+// "all", or a comma-separated list of user IDs.
+func parseNeverExpire(value string) (neverExpirePolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "none":
+		return neverExpirePolicy{}, nil
+	case "all":
+		return neverExpirePolicy{all: true}, nil
+	}
+
+	policy := neverExpirePolicy{userIDs: make(map[int]bool)}
+	for _, field := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || id < 1 {
+			return neverExpirePolicy{}, fmt.Errorf("-never-expire %q must be none, all or a comma-separated list of user IDs", value)
+		}
+		policy.userIDs[id] = true
+	}
+
+	return policy, nil
+}
+
+// allows reports whether the user may create snippets which never expire.
+func (p neverExpirePolicy) allows(userID int) bool {
+	return p.all || p.userIDs[userID]
+}
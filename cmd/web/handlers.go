@@ -9,14 +9,35 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
+	"math"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// homePageSize is the number of snippets listed per page on the home page.
+const homePageSize = 10
+
 // GET /
+// The snippets are paginated with keyset cursors: "?before=ID" lists the
+// snippets older than ID and "?after=ID" the ones newer than ID, so a deep
+// page costs the same as the first one.
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.GetTenLatestSnippets(r.Context())
+	before, err := parseCursor(r, "before")
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	after, err := parseCursor(r, "after")
+	if err != nil || (before != 0 && after != 0) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, cursor, err := app.listLiveSnippets(r.Context(), before, after, homePageSize)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -24,67 +45,285 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.Cursor = cursor
 
 	app.render(w, http.StatusOK, "home.html", data)
 }
 
+// GET /s/:slug
 // GET /snippet/view/:id
+// A snippet protected by a password shows the form unlocking it instead, and
+// a snippet deleted once read the form revealing it, so that link previews
+// can't delete it.
 func (app *application) viewSnippet(w http.ResponseWriter, r *http.Request) {
-	// params are parameters from URL path, not query parameters
-	params := httprouter.ParamsFromContext(r.Context())
+	snippet, ok := app.getLiveSnippet(w, r)
+	if !ok {
+		return
+	}
 
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		app.clientError(w, http.StatusNotFound)
+	if app.isSnippetLocked(r, snippet.ID, snippet.UserID, snippet.HashedPassword) {
+		app.renderUnlockSnippetPage(w, r, http.StatusOK, snippet, unlockSnippetFormResult{})
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	if app.mustRevealSnippet(r, snippet.UserID, snippet.BurnAfterReading) {
+		app.render(w, http.StatusOK, "reveal-snippet.html", data)
 		return
 	}
 
-	snippet, err := app.GetSnippetNotExpired(r.Context(), int32(id))
+	app.render(w, http.StatusOK, "view.html", data)
+}
+
+// POST /s/:slug/reveal
+// The snippet is deleted in the same transaction as it is read, so two
+// concurrent readers can't both see it: the second one gets a 404.
+func (app *application) doRevealSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.getLiveSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	path := fmt.Sprintf("/s/%s", snippet.Slug)
+
+	// Only a snippet which must be revealed is burnt, its owner reads it on its page.
+	if !app.mustRevealSnippet(r, snippet.UserID, snippet.BurnAfterReading) ||
+		app.isSnippetLocked(r, snippet.ID, snippet.UserID, snippet.HashedPassword) {
+		http.Redirect(w, r, path, http.StatusSeeOther)
+		return
+	}
+
+	burnt, err := app.BurnSnippetTx(r.Context(), snippet.ID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			app.clientError(w, http.StatusNotFound)
-			return
+		} else {
+			app.serverError(w, err)
 		}
-		app.serverError(w, err)
 		return
 	}
 
 	data := app.newTemplateData(r)
-	data.Snippet = snippet
+	data.Snippet = sqlc.GetSnippetNotExpiredRow(burnt)
+	data.Burnt = true
 
+	// The content can't be fetched again, so it must not be kept anywhere either.
+	w.Header().Set("Cache-Control", "no-store")
 	app.render(w, http.StatusOK, "view.html", data)
 }
 
-// GET /snippet/create
-func (app *application) displayCreateSnippetPage(w http.ResponseWriter, r *http.Request) {
-	data := app.newTemplateData(r)
-	data.Form = createSnippetFormResult{
-		// Other fields get zero-value.
-		Expires: 365, // The value "One year" of radio button "Delete in" is chosen by default.
+// GET /s/:slug/raw
+// GET /snippet/raw/:id
+func (app *application) viewRawSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.getLiveSnippet(w, r)
+	if !ok {
+		return
 	}
 
-	app.render(w, http.StatusOK, "create-snippet.html", data)
+	if app.isSnippetLocked(r, snippet.ID, snippet.UserID, snippet.HashedPassword) ||
+		app.mustRevealSnippet(r, snippet.UserID, snippet.BurnAfterReading) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	serveSnippetContent(w, r, snippet)
 }
 
-// createSnippetFormResult represents the form data and validation errors
-// for the form fields.
-type createSnippetFormResult struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+// GET /s/:slug/download
+// GET /snippet/download/:id
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.getLiveSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if app.isSnippetLocked(r, snippet.ID, snippet.UserID, snippet.HashedPassword) ||
+		app.mustRevealSnippet(r, snippet.UserID, snippet.BurnAfterReading) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": snippetFilename(snippet)})
+	w.Header().Set("Content-Disposition", disposition)
+
+	serveSnippetContent(w, r, snippet)
+}
+
+// Wrong snippet passwords are throttled for each client on a snippet, and for
+// all clients together on a snippet against guesses sent from many addresses.
+const (
+	unlockAttemptsPerClient  = 5
+	unlockAttemptsPerSnippet = 50
+	unlockAttemptsWindow     = 15 * time.Minute
+)
+
+// unlockSnippetFormResult represents the password given to unlock a protected snippet.
+type unlockSnippetFormResult struct {
+	Password            string `form:"password"`
 	validator.Validator `form:"-"`
 }
 
-// POST /snippet/create
-func (app *application) doCreateSnippet(w http.ResponseWriter, r *http.Request) {
-	var form createSnippetFormResult
+// renderUnlockSnippetPage renders the form asking for the password of a protected snippet.
+func (app *application) renderUnlockSnippetPage(w http.ResponseWriter, r *http.Request, status int, snippet sqlc.GetSnippetNotExpiredRow, form unlockSnippetFormResult) {
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = form
+
+	app.render(w, status, "unlock-snippet.html", data)
+}
+
+// POST /s/:slug/unlock
+// The unlocked snippet is remembered in the session, for this snippet only.
+func (app *application) doUnlockSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.getLiveSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	path := fmt.Sprintf("/s/%s", snippet.Slug)
+
+	if !app.isSnippetLocked(r, snippet.ID, snippet.UserID, snippet.HashedPassword) {
+		http.Redirect(w, r, path, http.StatusSeeOther)
+		return
+	}
+
+	var form unlockSnippetFormResult
 
 	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.formError(w, r, err)
+		return
+	}
+
+	if !validator.IsNotBlank(form.Password) {
+		form.AddFieldError("password", "This field cannot be blank")
+		app.renderUnlockSnippetPage(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	// Every attempt is counted before the password is checked, and the ones of
+	// a client are forgotten once it gives the right password.
+	clientKey := fmt.Sprintf("%d/%s", snippet.ID, clientIP(r))
+
+	retryAfter, allowed := app.clientUnlocks.allow(clientKey)
+	if allowed {
+		retryAfter, allowed = app.snippetUnlocks.allow(strconv.Itoa(int(snippet.ID)))
+	}
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		form.AddGenericError("Too many attempts, please try again later")
+		app.renderUnlockSnippetPage(w, r, http.StatusTooManyRequests, snippet, form)
+		return
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(snippet.HashedPassword.String), []byte(form.Password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			form.AddFieldError("password", "The password is incorrect")
+			app.renderUnlockSnippetPage(w, r, http.StatusUnprocessableEntity, snippet, form)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.clientUnlocks.reset(clientKey)
+	app.sessionManager.Put(r.Context(), unlockedSnippetKey(snippet.ID), snippet.HashedPassword.String)
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+// searchPageSize is the number of results listed per page on the search page.
+const searchPageSize = 10
+
+// GET /snippet/search?q=
+// The query supports the web search syntax: "quoted phrases", OR and -excluded words.
+func (app *application) searchSnippets(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	page, err := parsePage(r)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
+	data := app.newTemplateData(r)
+	data.Query = query
+	data.Page = pageInfo{Current: page, Query: query}
+
+	if query == "" {
+		app.render(w, http.StatusOK, "search.html", data)
+		return
+	}
+
+	// Fetch one more result than we display to know whether there is a next page.
+	results, err := app.SearchSnippets(r.Context(), sqlc.SearchSnippetsParams{
+		Query:      query,
+		PageSize:   searchPageSize + 1,
+		PageOffset: int32((page - 1) * searchPageSize),
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if page > 1 {
+		data.Page.Previous = page - 1
+	}
+	if len(results) > searchPageSize {
+		results = results[:searchPageSize]
+		data.Page.Next = page + 1
+	}
+
+	data.SearchResults = results
+
+	app.render(w, http.StatusOK, "search.html", data)
+}
+
+// GET /snippet/create
+func (app *application) displayCreateSnippetPage(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = createSnippetFormResult{
+		// Other fields get zero-value.
+		Expiry:         expiryAfter,
+		Expires:        365, // The snippets are deleted in one year by default.
+		ExpiresUnit:    defaultExpiresUnit,
+		Language:       defaultLanguage,
+		Format:         defaultFormat,
+		Visibility:     defaultVisibility,
+		CanNeverExpire: app.neverExpire.allows(app.authenticatedUserID(r)),
+	}
+
+	app.render(w, http.StatusOK, "create-snippet.html", data)
+}
+
+// createSnippetFormResult represents the form data and validation errors
+// for the form fields. The API decodes its JSON snippets into it too, so
+// both share the same validation rules.
+type createSnippetFormResult struct {
+	Title               string `form:"title" json:"title"`
+	Content             string `form:"content" json:"content"`
+	Expiry              string `form:"expiry" json:"expiry"`             // after, at or never
+	Expires             int    `form:"expires" json:"expires"`           // the duration of the "after" expiry, in ExpiresUnit
+	ExpiresUnit         string `form:"expires_unit" json:"expires_unit"` // minutes, hours or days
+	ExpiresAt           string `form:"expires_at" json:"expires_at"`     // the date-time of the "at" expiry
+	TimeZone            string `form:"time_zone" json:"time_zone"`       // of ExpiresAt, when it has no UTC offset
+	Language            string `form:"language" json:"language"`
+	Format              string `form:"format" json:"format"`
+	Visibility          string `form:"visibility" json:"visibility"`
+	BurnAfterReading    bool   `form:"burn_after_reading" json:"burn_after_reading"`
+	Password            string `form:"password" json:"password"`               // optional, a blank one keeps the current password
+	RemovePassword      bool   `form:"remove_password" json:"remove_password"` // used for unprotecting a snippet
+	Protected           bool   `form:"-" json:"-"`                             // whether the edited snippet has a password
+	CanNeverExpire      bool   `form:"-" json:"-"`                             // whether the user may choose the "never" expiry
+	validator.Validator `form:"-" json:"-"`
+	expiresTime         *time.Time // set by validate, nil when the snippet never expires
+}
+
+// validate checks the snippet fields, it is shared by the create and edit forms.
+func (form *createSnippetFormResult) validate() {
 	// validate title
 	if !validator.IsNotBlank(form.Title) {
 		form.AddFieldError("title", "This field cannot be blank")
@@ -98,11 +337,104 @@ func (app *application) doCreateSnippet(w http.ResponseWriter, r *http.Request)
 		form.AddFieldError("content", "This field cannot be blank")
 	}
 
-	// validate expires
-	if !validator.IsIntInList(form.Expires, 1, 7, 365) {
-		form.AddFieldError("expires", "This field must equal 1, 7 or 365")
+	// validate expiry
+	form.validateExpiry(time.Now())
+
+	// validate language
+	if !validator.IsStringInList(form.Language, optionNames(languages)...) {
+		form.AddFieldError("language", "This field must be one of the listed languages")
+	}
+
+	// validate format
+	if !validator.IsStringInList(form.Format, optionNames(formats)...) {
+		form.AddFieldError("format", "This field must equal plain, code or markdown")
+	}
+
+	// validate visibility
+	if !validator.IsStringInList(form.Visibility, optionNames(visibilities)...) {
+		form.AddFieldError("visibility", "This field must equal public, unlisted or private")
+	}
+
+	// validate password, which is optional
+	if form.Password != "" {
+		if !validator.IsStringNotLessThanLimit(form.Password, 8) {
+			form.AddFieldError("password", "This field must be at least 8 characters long")
+		}
+		// bcrypt only hashes the first 72 bytes.
+		if len(form.Password) > 72 {
+			form.AddFieldError("password", "This field cannot be more than 72 bytes long")
+		}
+	}
+}
+
+// validateExpiry checks the expiry fields and computes the expiry time from now.
+// Every error is reported on the "expires" field, which the forms show once.
+func (form *createSnippetFormResult) validateExpiry(now time.Time) {
+	form.expiresTime = nil
+
+	switch form.Expiry {
+	case expiryAfter:
+		unit, ok := findExpiresUnit(form.ExpiresUnit)
+		if !ok {
+			form.AddFieldError("expires", "The unit must equal minutes, hours or days")
+			return
+		}
+
+		max := int(maxExpiry / unit.Duration)
+		if !validator.IsIntInRange(form.Expires, 1, max) {
+			form.AddFieldError("expires", fmt.Sprintf("This field must be between 1 and %d %s", max, unit.Name))
+			return
+		}
+
+		expires := now.Add(time.Duration(form.Expires) * unit.Duration)
+		form.expiresTime = &expires
+	case expiryAt:
+		timeZone := form.TimeZone
+		if timeZone == "" {
+			timeZone = "UTC"
+		}
+
+		expires, ok := validator.ParseDateTime(form.ExpiresAt, timeZone)
+		if !ok {
+			if !validator.IsTimeZone(timeZone) {
+				form.AddFieldError("expires", "The time zone must be a name like Europe/Paris or UTC")
+			} else {
+				form.AddFieldError("expires", "The date-time must be like 2006-01-02T15:04")
+			}
+			return
+		}
+
+		if !validator.IsTimeInRange(expires, now, now.Add(maxExpiry)) {
+			form.AddFieldError("expires", "The date-time must be in the future, and within a year")
+			return
+		}
+
+		form.expiresTime = &expires
+	case expiryNever:
+		if !form.CanNeverExpire {
+			form.AddFieldError("expires", "You are not allowed to keep snippets forever")
+		}
+	default:
+		form.AddFieldError("expires", "This field must equal after, at or never")
+	}
+}
+
+// snippetSlugBytes is the number of random bytes of the slug in the share URL of a snippet.
+const snippetSlugBytes = 16
+
+// POST /snippet/create
+func (app *application) doCreateSnippet(w http.ResponseWriter, r *http.Request) {
+	var form createSnippetFormResult
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.formError(w, r, err)
+		return
 	}
 
+	form.CanNeverExpire = app.neverExpire.allows(app.authenticatedUserID(r))
+	form.validate()
+
 	// If there are any validation errors, re-display the create-snippet.html with error notifications.
 	// The URL path still does not change.
 	if !form.IsNoErrors() {
@@ -113,13 +445,34 @@ func (app *application) doCreateSnippet(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	userID := app.authenticatedUserID(r)
+
+	slug, err := generateRandomToken(snippetSlugBytes)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	hashedPassword, err := app.snippetPasswordHash(form, sql.NullString{})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	arg := sqlc.CreateSnippetParams{
-		Title:    form.Title,
-		Content:  form.Content,
-		Duration: int32(form.Expires),
+		Title:            form.Title,
+		Content:          form.Content,
+		Expires:          form.expiresTime,
+		UserID:           int32(userID),
+		Language:         form.Language,
+		Format:           form.Format,
+		Visibility:       form.Visibility,
+		Slug:             slug,
+		HashedPassword:   hashedPassword,
+		BurnAfterReading: form.BurnAfterReading,
 	}
 
-	snippet, err := app.CreateSnippet(r.Context(), arg)
+	_, err = app.CreateSnippet(r.Context(), arg)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -127,7 +480,116 @@ func (app *application) doCreateSnippet(w http.ResponseWriter, r *http.Request)
 
 	app.sessionManager.Put(r.Context(), "flash", "Create snippet successfully.")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%v", snippet), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", slug), http.StatusSeeOther)
+}
+
+// GET /snippet/edit/:id
+func (app *application) displayEditSnippetPage(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.getOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	form := createSnippetFormResult{
+		Title:            snippet.Title,
+		Content:          snippet.Content,
+		Language:         snippet.Language,
+		Format:           snippet.Format,
+		Visibility:       snippet.Visibility,
+		Protected:        snippet.HashedPassword.Valid,
+		BurnAfterReading: snippet.BurnAfterReading,
+		Expires:          365,
+		ExpiresUnit:      defaultExpiresUnit,
+		CanNeverExpire:   app.neverExpire.allows(app.authenticatedUserID(r)),
+	}
+
+	// The current expiry is kept unless another one is chosen, it is shown in UTC.
+	if snippet.Expires == nil {
+		form.Expiry = expiryNever
+	} else {
+		form.Expiry = expiryAt
+		form.ExpiresAt = snippet.Expires.UTC().Format(validator.LocalDateTimeLayout)
+		form.TimeZone = "UTC"
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet.ID = snippet.ID
+	data.Form = form
+
+	app.render(w, http.StatusOK, "edit-snippet.html", data)
+}
+
+// POST /snippet/edit/:id
+func (app *application) doEditSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.getOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form createSnippetFormResult
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.formError(w, r, err)
+		return
+	}
+
+	form.Protected = snippet.HashedPassword.Valid
+	form.CanNeverExpire = app.neverExpire.allows(app.authenticatedUserID(r))
+	form.validate()
+
+	if !form.IsNoErrors() {
+		data := app.newTemplateData(r)
+		data.Snippet.ID = snippet.ID
+		data.Form = form
+
+		app.render(w, http.StatusUnprocessableEntity, "edit-snippet.html", data)
+		return
+	}
+
+	hashedPassword, err := app.snippetPasswordHash(form, snippet.HashedPassword)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.UpdateSnippet(r.Context(), sqlc.UpdateSnippetParams{
+		Title:            form.Title,
+		Content:          form.Content,
+		Language:         form.Language,
+		Format:           form.Format,
+		Visibility:       form.Visibility,
+		HashedPassword:   hashedPassword,
+		BurnAfterReading: form.BurnAfterReading,
+		Expires:          form.expiresTime,
+		ID:               snippet.ID,
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Update snippet successfully.")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+// POST /snippet/delete/:id
+func (app *application) doDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.getOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.DeleteSnippet(r.Context(), snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Delete snippet successfully.")
+
+	http.Redirect(w, r, "/account/snippets", http.StatusSeeOther)
 }
 
 // GET /user/signup
@@ -151,7 +613,7 @@ func (app *application) doSignupUser(w http.ResponseWriter, r *http.Request) {
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.formError(w, r, err)
 		return
 	}
 
@@ -188,7 +650,7 @@ func (app *application) doSignupUser(w http.ResponseWriter, r *http.Request) {
 
 	// Else, try to insert user's information to database.
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(form.Password), 12)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(form.Password), app.config.bcryptCost)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -245,7 +707,7 @@ func (app *application) doLoginUser(w http.ResponseWriter, r *http.Request) {
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.formError(w, r, err)
 		return
 	}
 
@@ -317,6 +779,9 @@ func (app *application) doLoginUser(w http.ResponseWriter, r *http.Request) {
 	// 'logged in'.
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", int(user.ID))
 
+	// Drop the CSRF token, so a new one is issued for the authenticated session.
+	app.sessionManager.Remove(r.Context(), "csrfToken")
+
 	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
 	if path != "" {
 		http.Redirect(w, r, path, http.StatusSeeOther)
@@ -340,6 +805,7 @@ func (app *application) doLogoutUser(w http.ResponseWriter, r *http.Request) {
 	// Remove the authenticatedUserID from the session data so that user is
 	// 'logged out'.
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "csrfToken")
 
 	// Add a flash message to the session to confirm to the user that they've been
 	// logged out.
@@ -357,7 +823,7 @@ func (app *application) about(w http.ResponseWriter, r *http.Request) {
 
 // GET /account/view
 func (app *application) viewAccount(w http.ResponseWriter, r *http.Request) {
-	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	userID := app.authenticatedUserID(r)
 
 	user, err := app.GetUserByID(r.Context(), int32(userID))
 	if err != nil {
@@ -371,6 +837,46 @@ func (app *application) viewAccount(w http.ResponseWriter, r *http.Request) {
 	app.render(w, http.StatusOK, "account.html", data)
 }
 
+// userSnippetsPageSize is the number of snippets listed per page on the "My snippets" page.
+const userSnippetsPageSize = 20
+
+// GET /account/snippets
+func (app *application) viewUserSnippets(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePage(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	// Fetch one more snippet than we display to know whether there is a next page.
+	snippets, err := app.ListSnippetsByUser(r.Context(), sqlc.ListSnippetsByUserParams{
+		UserID: int32(userID),
+		Limit:  userSnippetsPageSize + 1,
+		Offset: int32((page - 1) * userSnippetsPageSize),
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Page = pageInfo{Current: page}
+
+	if page > 1 {
+		data.Page.Previous = page - 1
+	}
+	if len(snippets) > userSnippetsPageSize {
+		snippets = snippets[:userSnippetsPageSize]
+		data.Page.Next = page + 1
+	}
+
+	data.UserSnippets = snippets
+
+	app.render(w, http.StatusOK, "user-snippets.html", data)
+}
+
 func (app *application) displayChangeUserPasswordPage(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = changeUserPasswordFormResult{}
@@ -390,7 +896,7 @@ func (app *application) doUpdateUserPassword(w http.ResponseWriter, r *http.Requ
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.formError(w, r, err)
 		return
 	}
 
@@ -426,7 +932,7 @@ func (app *application) doUpdateUserPassword(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	userId := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	userId := app.authenticatedUserID(r)
 
 	userPassword, err := app.GetPasswordByID(r.Context(), int32(userId))
 	if err != nil {
@@ -449,7 +955,7 @@ func (app *application) doUpdateUserPassword(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	newUserPassword, err := bcrypt.GenerateFromPassword([]byte(form.NewPassword), 12)
+	newUserPassword, err := bcrypt.GenerateFromPassword([]byte(form.NewPassword), app.config.bcryptCost)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -468,3 +974,130 @@ func (app *application) doUpdateUserPassword(w http.ResponseWriter, r *http.Requ
 
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
+
+// apiTokenBytes is the number of random bytes of a personal API token.
+const apiTokenBytes = 32
+
+type createAPITokenFormResult struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	Expires             int      `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+// HasScope reports whether the scope is checked in the form.
+func (form createAPITokenFormResult) HasScope(scope string) bool {
+	return validator.IsStringInList(scope, form.Scopes...)
+}
+
+// renderAPITokensPage renders the page listing the API tokens of the user, with the form creating a new one.
+func (app *application) renderAPITokensPage(w http.ResponseWriter, r *http.Request, status int, form createAPITokenFormResult) {
+	tokens, err := app.ListAPITokensByUser(r.Context(), int32(app.authenticatedUserID(r)))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.APITokens = tokens
+	data.Form = form
+	// The new token is only kept in the session until it is shown once.
+	data.NewAPIToken = app.sessionManager.PopString(r.Context(), "newAPIToken")
+
+	app.render(w, status, "api-tokens.html", data)
+}
+
+// GET /account/tokens
+func (app *application) viewAPITokens(w http.ResponseWriter, r *http.Request) {
+	app.renderAPITokensPage(w, r, http.StatusOK, createAPITokenFormResult{
+		Scopes:  []string{scopeSnippetsRead},
+		Expires: 90,
+	})
+}
+
+// POST /account/tokens
+func (app *application) doCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var form createAPITokenFormResult
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.formError(w, r, err)
+		return
+	}
+
+	if !validator.IsNotBlank(form.Name) {
+		form.AddFieldError("name", "This field cannot be blank")
+	}
+	if !validator.IsStringNotExceedLimit(form.Name, 100) {
+		form.AddFieldError("name", "This field cannot be more than 100 characters")
+	}
+
+	if len(form.Scopes) == 0 {
+		form.AddFieldError("scopes", "At least one scope must be checked")
+	}
+	for _, scope := range form.Scopes {
+		if !validator.IsStringInList(scope, optionNames(apiScopes)...) {
+			form.AddFieldError("scopes", "This field must only contain the listed scopes")
+		}
+	}
+
+	if !validator.IsIntInList(form.Expires, 30, 90, 365) {
+		form.AddFieldError("expires", "This field must equal 30, 90 or 365")
+	}
+
+	if !form.IsNoErrors() {
+		app.renderAPITokensPage(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	token, err := generateRandomToken(apiTokenBytes)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.CreateAPIToken(r.Context(), sqlc.CreateAPITokenParams{
+		UserID:   int32(app.authenticatedUserID(r)),
+		Name:     form.Name,
+		Hash:     hashAPIToken(token),
+		Scopes:   form.Scopes,
+		Duration: int32(form.Expires),
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "newAPIToken", token)
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// POST /account/tokens/revoke/:id
+func (app *application) doRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusNotFound)
+		return
+	}
+
+	// Only the tokens of the user are deleted, the others are as good as missing.
+	deleted, err := app.DeleteAPIToken(r.Context(), sqlc.DeleteAPITokenParams{
+		ID:     int32(id),
+		UserID: int32(app.authenticatedUserID(r)),
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if deleted == 0 {
+		app.clientError(w, http.StatusNotFound)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Revoke API token successfully.")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
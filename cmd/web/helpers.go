@@ -2,11 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/chauvinhphuoc/snippetbox/internal/db/sqlc"
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/crypto/bcrypt"
+	"html/template"
+	"math"
 	"net/http"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,20 +30,133 @@ func (app *application) serverError(w http.ResponseWriter, err error) {
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
+// templateError responds to an error while parsing or executing a template. In
+// development mode it shows the error in the browser, otherwise it is handled
+// like any other server error.
+func (app *application) templateError(w http.ResponseWriter, err error) {
+	if !app.config.dev {
+		app.serverError(w, err)
+		return
+	}
+
+	app.errorLog.Output(2, err.Error())
+
+	// The page has inline styles only, and nothing else to load.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	err = devErrorTemplate.Execute(w, err.Error())
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
 // clientError sends a specific status code and corresponding description to the user.
 func (app *application) clientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+// formError responds to a form which couldn't be parsed or decoded. A body over
+// the size limit gets the 413 error page, anything else is a bad request.
+func (app *application) formError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		message := fmt.Sprintf("The submitted data is larger than the limit of %d KB.", maxBytesError.Limit>>10)
+		app.renderErrorPage(w, r, http.StatusRequestEntityTooLarge, message)
+		return
+	}
+
+	app.clientError(w, http.StatusBadRequest)
+}
+
+// renderErrorPage renders the error page with the status and a message for the user.
+func (app *application) renderErrorPage(w http.ResponseWriter, r *http.Request, status int, message string) {
+	data := app.newTemplateData(r)
+	data.Error = errorPage{
+		Status:  status,
+		Title:   http.StatusText(status),
+		Message: message,
+	}
+
+	app.render(w, status, "error.html", data)
+}
+
+// option is an item of a form choice, like a language or a visibility, whose
+// name is the submitted value.
+type option interface {
+	optionName() string
+}
+
+// optionNames returns the names of the options, for validating a form.
+func optionNames[T option](options []T) []string {
+	names := make([]string, len(options))
+	for i, o := range options {
+		names[i] = o.optionName()
+	}
+
+	return names
+}
+
 // humanDate returns a nicely formatted string representation
 // of a time.Time object.
 func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// isExpired reports whether the expiry time has already passed.
+func isExpired(expires time.Time) bool {
+	return !time.Now().Before(expires)
+}
+
+// expiresIn returns a short countdown like "6d 23h" or "15m" until the expiry time,
+// or "-" if it has already passed.
+func expiresIn(expires time.Time) string {
+	left := time.Until(expires)
+	if left <= 0 {
+		return "-"
+	}
+
+	days := int(left / (24 * time.Hour))
+	hours := int(left % (24 * time.Hour) / time.Hour)
+	minutes := int(left % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// headlineMarks turns the STX and ETX characters, which mark the matched words
+// in a search headline, into <mark> elements.
+var headlineMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// highlight returns a search headline as HTML, with the matched words marked.
+// The headline is escaped first, so the content can't inject any HTML.
+func highlight(headline string) template.HTML {
+	return template.HTML(headlineMarks.Replace(template.HTMLEscapeString(headline)))
+}
+
 // render retrieves the appropriate template set from the cache,
 // write status code and execute that template set.
+// In development mode, the template set is parsed again from the template
+// files on every call, so changes show up without restarting the server.
 func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+	if app.config.dev {
+		ts, err := parseTemplateSet(app.templateFS, "pages/"+page, app.staticFiles.templateFuncs())
+		if err != nil {
+			app.templateError(w, err)
+			return
+		}
+
+		app.execute(w, status, ts, data)
+		return
+	}
+
 	ts, ok := app.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template set %s does not exist", page)
@@ -39,13 +164,17 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 		return
 	}
 
-	// TODO: Write other headers.
+	app.execute(w, status, ts, data)
+}
 
+// execute writes the status code and the output of the template set, only
+// after the whole output was rendered without error.
+func (app *application) execute(w http.ResponseWriter, status int, ts *template.Template, data *templateData) {
 	buf := new(bytes.Buffer)
 
 	err := ts.ExecuteTemplate(buf, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		app.templateError(w, err)
 		return
 	}
 
@@ -58,6 +187,7 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 // and map to corresponding fields based on struct tags in dst.
 func (app *application) decodePostForm(r *http.Request, dst any) error {
 	// r.ParseForm() adds any data in POST request bodies to the r.PostForm map.
+	// The body is already capped by the limitRequestBody middleware.
 	err := r.ParseForm()
 	if err != nil {
 		app.errorLog.Print(err)
@@ -92,3 +222,286 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// authenticatedUserID returns the ID of the user who made the request, which
+// the authenticate and authenticateToken middlewares put in the request
+// context, or 0 if the request isn't authenticated.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(userIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// getOwnedSnippet fetches the snippet whose ID is in the URL path and checks that
+// it belongs to the current user. If it doesn't, an error response is already sent
+// and ok is false.
+func (app *application) getOwnedSnippet(w http.ResponseWriter, r *http.Request) (snippet sqlc.Snippet, ok bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusNotFound)
+		return snippet, false
+	}
+
+	snippet, err = app.GetSnippet(r.Context(), int32(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, err)
+		}
+		return snippet, false
+	}
+
+	userID := app.authenticatedUserID(r)
+	if snippet.UserID != int32(userID) {
+		app.clientError(w, http.StatusForbidden)
+		return snippet, false
+	}
+
+	return snippet, true
+}
+
+// getLiveSnippet returns the snippet whose slug or ID is in the URL path, if it
+// has not expired and the authenticated user may read it: any snippet but the
+// private ones by slug, only the public ones by ID, and their own either way.
+// Otherwise it writes a 404 Not Found response (or 500 on database error) and
+// ok is false.
+func (app *application) getLiveSnippet(w http.ResponseWriter, r *http.Request) (snippet sqlc.GetSnippetNotExpiredRow, ok bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	userID := app.authenticatedUserID(r)
+
+	var err error
+
+	if slug := params.ByName("slug"); slug != "" {
+		var row sqlc.GetSnippetBySlugRow
+		row, err = app.GetSnippetBySlug(r.Context(), sqlc.GetSnippetBySlugParams{Slug: slug, ViewerID: int32(userID)})
+		snippet = sqlc.GetSnippetNotExpiredRow(row)
+	} else {
+		var id int
+		id, err = strconv.Atoi(params.ByName("id"))
+		if err != nil || id < 1 {
+			app.clientError(w, http.StatusNotFound)
+			return snippet, false
+		}
+
+		snippet, err = app.GetSnippetNotExpired(r.Context(), sqlc.GetSnippetNotExpiredParams{ID: int32(id), ViewerID: int32(userID)})
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.clientError(w, http.StatusNotFound)
+		} else {
+			app.serverError(w, err)
+		}
+		return snippet, false
+	}
+
+	return snippet, true
+}
+
+// unlockedSnippetKey returns the session key remembering that the password of the snippet was given.
+func unlockedSnippetKey(id int32) string {
+	return fmt.Sprintf("unlockedSnippet:%d", id)
+}
+
+// isSnippetLocked reports whether the snippet is protected by a password which
+// hasn't been given in this session yet. Owners never have to give the password
+// of their own snippets. The session keeps the hash which was unlocked, so
+// changing the password locks the snippet again.
+func (app *application) isSnippetLocked(r *http.Request, id, ownerID int32, hashedPassword sql.NullString) bool {
+	if !hashedPassword.Valid || ownerID == int32(app.authenticatedUserID(r)) {
+		return false
+	}
+
+	return app.sessionManager.GetString(r.Context(), unlockedSnippetKey(id)) != hashedPassword.String
+}
+
+// mustRevealSnippet reports whether the snippet is deleted once read, so its
+// content is only shown through its reveal form. Its owner can read it without
+// deleting it.
+func (app *application) mustRevealSnippet(r *http.Request, ownerID int32, burnAfterReading bool) bool {
+	return burnAfterReading && ownerID != int32(app.authenticatedUserID(r))
+}
+
+// snippetPasswordHash returns the password hash to store for a snippet from the
+// form, given the current one: a new password replaces it, RemovePassword
+// clears it, and it is kept otherwise.
+func (app *application) snippetPasswordHash(form createSnippetFormResult, current sql.NullString) (sql.NullString, error) {
+	switch {
+	case form.Password != "":
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(form.Password), app.config.bcryptCost)
+		if err != nil {
+			return current, err
+		}
+		return sql.NullString{String: string(hashedPassword), Valid: true}, nil
+	case form.RemovePassword:
+		return sql.NullString{}, nil
+	default:
+		return current, nil
+	}
+}
+
+// serveSnippetContent writes the content of the snippet as plain text. The ETag
+// is the hash of the content and Last-Modified the time of the last edit, so
+// http.ServeContent answers conditional and range requests. Clients must check
+// again before reusing their copy, since the snippet may have expired since.
+func serveSnippetContent(w http.ResponseWriter, r *http.Request, snippet sqlc.GetSnippetNotExpiredRow) {
+	sum := sha256.Sum256([]byte(snippet.Content))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, r, "", snippet.UpdatedAt, strings.NewReader(snippet.Content))
+}
+
+// nonSlugCharacters matches the runs of characters replaced by a dash in file names.
+var nonSlugCharacters = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// snippetFilename returns the name of the downloaded file of a snippet, made of
+// its title and the extension of its format or language, like "my-query.sql".
+func snippetFilename(snippet sqlc.GetSnippetNotExpiredRow) string {
+	name := strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(snippet.Title), "-"), "-")
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", snippet.ID)
+	}
+
+	extension := ".txt"
+
+	switch snippet.Format {
+	case "markdown":
+		extension = ".md"
+	case "code":
+		for _, l := range languages {
+			if l.Name == snippet.Language {
+				extension = l.Extension
+				break
+			}
+		}
+	}
+
+	return name + extension
+}
+
+// parsePage returns the page number in the "page" query parameter of the URL,
+// or 1 when the parameter is missing.
+func parsePage(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("page")
+	if value == "" {
+		return 1, nil
+	}
+
+	page, err := strconv.Atoi(value)
+	if err != nil || page < 1 {
+		return 0, fmt.Errorf("invalid page %q", value)
+	}
+
+	return page, nil
+}
+
+// listLiveSnippets returns a page of the snippets which have not expired, newest
+// first, with the cursors of the pages around it. The page is the one before the
+// snippet ID before, or the one after the snippet ID after when it isn't zero.
+func (app *application) listLiveSnippets(ctx context.Context, before, after int32, pageSize int) ([]sqlc.ListSnippetsBeforeRow, cursorInfo, error) {
+	var (
+		snippets []sqlc.ListSnippetsBeforeRow
+		cursor   cursorInfo
+	)
+
+	// Fetch one more snippet than we display to know whether there is another page.
+	if after != 0 {
+		rows, err := app.ListSnippetsAfter(ctx, sqlc.ListSnippetsAfterParams{
+			After:    after,
+			PageSize: int32(pageSize) + 1,
+		})
+		if err != nil {
+			return nil, cursor, err
+		}
+
+		if len(rows) > pageSize {
+			rows = rows[:pageSize]
+			cursor.Newer = rows[len(rows)-1].ID
+		}
+
+		// The rows are in ascending order, but the page lists the newest first.
+		for i := len(rows) - 1; i >= 0; i-- {
+			snippets = append(snippets, sqlc.ListSnippetsBeforeRow(rows[i]))
+		}
+
+		// We came from a newer page, so there are older snippets.
+		if len(snippets) > 0 {
+			cursor.Older = snippets[len(snippets)-1].ID
+		}
+	} else {
+		if before == 0 {
+			before = math.MaxInt32
+		}
+
+		var err error
+
+		snippets, err = app.ListSnippetsBefore(ctx, sqlc.ListSnippetsBeforeParams{
+			Before:   before,
+			PageSize: int32(pageSize) + 1,
+		})
+		if err != nil {
+			return nil, cursor, err
+		}
+
+		if len(snippets) > pageSize {
+			snippets = snippets[:pageSize]
+			cursor.Older = snippets[len(snippets)-1].ID
+		}
+
+		// We came from an older page, so there are newer snippets.
+		if before != math.MaxInt32 && len(snippets) > 0 {
+			cursor.Newer = snippets[0].ID
+		}
+	}
+
+	return snippets, cursor, nil
+}
+
+// parseCursor returns the snippet ID in the query parameter key of the URL,
+// or 0 when the parameter is missing.
+func parseCursor(r *http.Request, key string) (int32, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid cursor %q", value)
+	}
+
+	return int32(id), nil
+}
+
+// csrfToken returns the CSRF token of the session, and creates it on the first
+// call, so that only the sessions which render a form are stored.
+func (app *application) csrfToken(r *http.Request) (string, error) {
+	token := app.sessionManager.GetString(r.Context(), "csrfToken")
+	if token != "" {
+		return token, nil
+	}
+
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	app.sessionManager.Put(r.Context(), "csrfToken", token)
+
+	return token, nil
+}
+
+// cspNonce returns the Content-Security-Policy nonce of the request, which is
+// set by the secureHeaders middleware.
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceContextKey).(string)
+	return nonce
+}
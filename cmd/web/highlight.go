@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"html/template"
+	"strings"
+)
+
+// language is a language which snippets can be highlighted as. Name is the
+// value stored in the database and the name of the chroma lexer, Extension
+// is the one of the downloaded files.
+type language struct {
+	Name      string
+	Label     string
+	Extension string
+}
+
+func (l language) optionName() string { return l.Name }
+
+// defaultLanguage is the language of snippets which must not be highlighted.
+const defaultLanguage = "plaintext"
+
+// languages lists the languages offered by the snippet forms, in the order they are shown.
+var languages = []language{
+	{defaultLanguage, "Plain text", ".txt"},
+	{"go", "Go", ".go"},
+	{"sql", "SQL", ".sql"},
+	{"bash", "Shell", ".sh"},
+	{"javascript", "JavaScript", ".js"},
+	{"python", "Python", ".py"},
+	{"json", "JSON", ".json"},
+	{"yaml", "YAML", ".yaml"},
+	{"html", "HTML", ".html"},
+	{"css", "CSS", ".css"},
+	{"docker", "Dockerfile", ".dockerfile"},
+	{"makefile", "Makefile", ".mk"},
+}
+
+// codeFormatter writes the highlighted tokens as <span> elements with CSS
+// classes instead of inline styles, which the Content-Security-Policy blocks.
+// The classes are styled by ui/static/css/highlight.css. The <pre> element is
+// left to the templates.
+var codeFormatter = html.New(html.WithClasses(true), html.PreventSurroundingPre(true))
+
+// highlightStyle is the chroma style written into ui/static/css/highlight.css,
+// run "go generate ./cmd/web" after changing it.
+//
+//go:generate go run highlight_css.go -style github -out ../../ui/static/css/highlight.css
+const highlightStyle = "github"
+
+// highlightCode returns the code as HTML highlighted for the language. The
+// formatter escapes the text of every token, so the content can't inject any
+// HTML. The code is only escaped when the language is unknown or the lexer fails.
+func highlightCode(code, languageName string) template.HTML {
+	lexer := lexers.Get(languageName)
+	if lexer == nil || languageName == defaultLanguage {
+		return template.HTML(template.HTMLEscapeString(code))
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(code))
+	}
+
+	var b strings.Builder
+
+	err = codeFormatter.Format(&b, styles.Get(highlightStyle), iterator)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(code))
+	}
+
+	return template.HTML(b.String())
+}
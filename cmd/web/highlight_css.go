@@ -0,0 +1,32 @@
+//go:build ignore
+
+// This program writes the stylesheet of the CSS classes used by the
+// highlighted snippets. It is run by "go generate" from cmd/web.
+package main
+
+import (
+	"flag"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"log"
+	"os"
+)
+
+func main() {
+	style := flag.String("style", "github", "chroma style")
+	out := flag.String("out", "highlight.css", "output file")
+	flag.Parse()
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	formatter := html.New(html.WithClasses(true))
+
+	err = formatter.WriteCSS(file, styles.Get(*style))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"html/template"
+)
+
+// format is a way of showing the content of snippets. Name is the value stored in the database.
+type format struct {
+	Name  string
+	Label string
+}
+
+func (f format) optionName() string { return f.Name }
+
+// defaultFormat is the format of snippets shown as they were typed.
+const defaultFormat = "plain"
+
+// formats lists the formats offered by the snippet forms, in the order they are shown.
+var formats = []format{
+	{defaultFormat, "Plain text"},
+	{"code", "Code"},
+	{"markdown", "Markdown"},
+}
+
+// markdownRenderer converts GitHub Flavored Markdown. It leaves out any raw
+// HTML of the source, which is sanitized afterwards anyway.
+var markdownRenderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// markdownPolicy only keeps the elements and attributes which can't run
+// scripts or change the page outside of the snippet.
+var markdownPolicy = bluemonday.UGCPolicy()
+
+// markdown returns the Markdown source rendered as sanitized HTML. The source
+// is only escaped when it can't be rendered.
+func markdown(source string) template.HTML {
+	var b bytes.Buffer
+
+	err := markdownRenderer.Convert([]byte(source), &b)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(source))
+	}
+
+	return template.HTML(markdownPolicy.SanitizeBytes(b.Bytes()))
+}
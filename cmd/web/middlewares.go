@@ -2,9 +2,91 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/chauvinhphuoc/snippetbox/internal/validator"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+// cspNoncePlaceholder is replaced in the configured Content-Security-Policy by
+// the nonce of the request.
+const cspNoncePlaceholder = "{nonce}"
+
+// recoverPanic turns a panic in any later handler into a 500 response,
+// instead of letting the server close the connection without responding.
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This deferred function always runs when Go unwinds the stack after a panic.
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			// http.ErrAbortHandler is used on purpose to abort a response,
+			// so let the server handle it as usual.
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			// The connection may be in an unknown state after a panic,
+			// so make the server close it after sending the response.
+			w.Header().Set("Connection", "close")
+			app.serverError(w, fmt.Errorf("%v", err))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secureHeaders sets the security headers of every response. When the
+// Content-Security-Policy uses a nonce, a new one is generated per request
+// and put in the request context, so templates can add it to inline scripts.
+func (app *application) secureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		csp := app.config.headers.csp
+
+		if strings.Contains(csp, cspNoncePlaceholder) {
+			nonce, err := generateRandomToken(16)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+
+			csp = strings.ReplaceAll(csp, cspNoncePlaceholder, nonce)
+
+			ctx := context.WithValue(r.Context(), cspNonceContextKey, nonce)
+			r = r.WithContext(ctx)
+		}
+
+		if csp != "" {
+			w.Header().Set("Content-Security-Policy", csp)
+		}
+
+		w.Header().Set("Referrer-Policy", app.config.headers.referrerPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "deny")
+		// Disable the legacy XSS auditor of old browsers, it has caused more
+		// vulnerabilities than it prevented. The CSP replaces it.
+		w.Header().Set("X-XSS-Protection", "0")
+
+		// Browsers ignore HSTS received over plain HTTP.
+		if r.TLS != nil && app.config.headers.hstsMaxAge > 0 {
+			maxAge := strconv.Itoa(int(app.config.headers.hstsMaxAge.Seconds()))
+			w.Header().Set("Strict-Transport-Security", "max-age="+maxAge+"; includeSubDomains")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
@@ -12,6 +94,15 @@ func (app *application) logRequest(next http.Handler) http.Handler {
 	})
 }
 
+// limitRequestBody caps the size of every request body, so a huge upload
+// can't exhaust the memory of the server while the form is being parsed.
+func (app *application) limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, app.config.server.maxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// If the user is not authenticated, redirect them to the login page and
@@ -33,6 +124,22 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireAPIAuthentication is the API counterpart of requireAuthentication,
+// it answers unauthenticated requests with a JSON 401 instead of a redirect.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.apiError(w, http.StatusUnauthorized, "You must be authenticated to use this endpoint")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Retrieve the authenticatedUserID value from the session using the
@@ -56,9 +163,10 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// If a matching user is found, we know that the request is
 		// coming from an authenticated user who exists in our database. We
 		// create a new copy of the request (with an isAuthenticatedContextKey
-		// value of true in the request context) and assign it to r.
+		// value of true and the user ID in the request context) and assign it to r.
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, userIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
@@ -66,3 +174,129 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// preventCSRF rejects any state-changing request whose "csrf_token" form value
+// doesn't match the CSRF token of the session. The token is created by the
+// first page rendering a form, see csrfToken.
+func (app *application) preventCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Safe methods don't change anything, so there is nothing to verify.
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			app.formError(w, r, err)
+			return
+		}
+
+		// A session without a token never rendered a form, so nothing can match.
+		// Use a constant-time comparison, so the time taken doesn't leak
+		// how many leading characters of the token were guessed right.
+		token := app.sessionManager.GetString(r.Context(), "csrfToken")
+		submitted := r.PostForm.Get("csrf_token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preventAPICSRF is the API counterpart of preventCSRF. A write authenticated
+// with the session cookie must have the application/json content type, which a
+// browser can't send across origins without asking first, even when the request
+// has no body like a DELETE. A request carrying an API token can't be forged by
+// another site, so it is let through.
+func (app *application) preventAPICSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, withToken := r.Context().Value(apiScopesContextKey).([]string)
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if !withToken && mediaType != "application/json" {
+			app.apiError(w, http.StatusUnsupportedMediaType, "The request must be application/json")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateToken authenticates the API requests carrying a personal API token
+// in an "Authorization: Bearer <token>" header, like authenticate does with the
+// session: it puts isAuthenticatedContextKey, the user ID and the scopes of the
+// token in the request context. A request with an invalid or expired token is
+// rejected, rather than served as if it were anonymous.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the token, so caches must not share it.
+		w.Header().Add("Vary", "Authorization")
+
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The scheme is case-insensitive, so "bearer" is as good as "Bearer".
+		scheme, token, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			app.invalidTokenError(w)
+			return
+		}
+
+		apiToken, err := app.GetAPITokenByHash(r.Context(), hashAPIToken(token))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				app.invalidTokenError(w)
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		err = app.UpdateAPITokenLastUsed(r.Context(), apiToken.ID)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, userIDContextKey, int(apiToken.UserID))
+		ctx = context.WithValue(ctx, apiScopesContextKey, apiToken.Scopes)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScope rejects the requests authenticated with an API token which doesn't
+// grant the scope. The requests authenticated with the session, or not at all,
+// are let through: the handler or requireAPIAuthentication deal with them.
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := r.Context().Value(apiScopesContextKey).([]string)
+			if ok && !validator.IsStringInList(scope, scopes...) {
+				app.apiError(w, http.StatusForbidden, fmt.Sprintf("The API token doesn't grant the %s scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// generateRandomToken returns a random, URL-safe token of n bytes.
+func generateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
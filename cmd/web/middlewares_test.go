@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	app := newTestApplication(t)
+
+	var errorLog bytes.Buffer
+	app.errorLog = log.New(&errorLog, "", 0)
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something went wrong")
+	})
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	app.standard().Then(panicking).ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d; want %d", rr.Code, http.StatusInternalServerError)
+	}
+
+	if got := rr.Header().Get("Connection"); got != "close" {
+		t.Errorf("got Connection header %q; want %q", got, "close")
+	}
+
+	// The log holds the panic value followed by the stack trace of the handler.
+	logged := errorLog.String()
+	if !strings.Contains(logged, "something went wrong") {
+		t.Errorf("got error log %q; want it to contain the panic value", logged)
+	}
+	if !strings.Contains(logged, "goroutine ") || !strings.Contains(logged, "TestRecoverPanic") {
+		t.Errorf("got error log %q; want it to contain the stack trace of the panic", logged)
+	}
+}
+
+func TestRecoverPanicAbortHandler(t *testing.T) {
+	app := newTestApplication(t)
+
+	aborting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	defer func() {
+		err := recover()
+		if err != http.ErrAbortHandler {
+			t.Errorf("got panic %v; want http.ErrAbortHandler", err)
+		}
+	}()
+
+	app.recoverPanic(aborting).ServeHTTP(rr, r)
+}
@@ -12,13 +12,20 @@ import (
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
-	fileServer := http.FileServer(http.Dir("./ui/static"))
-	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static/", fileServer))
+	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", app.staticFiles))
 
-	dynamic := alice.New(app.sessionManager.LoadAndSave, app.authenticate)
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.preventCSRF, app.authenticate)
 
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
+	router.Handler(http.MethodGet, "/s/:slug", dynamic.ThenFunc(app.viewSnippet))
+	router.Handler(http.MethodGet, "/s/:slug/raw", dynamic.ThenFunc(app.viewRawSnippet))
+	router.Handler(http.MethodGet, "/s/:slug/download", dynamic.ThenFunc(app.downloadSnippet))
+	router.Handler(http.MethodPost, "/s/:slug/unlock", dynamic.ThenFunc(app.doUnlockSnippet))
+	router.Handler(http.MethodPost, "/s/:slug/reveal", dynamic.ThenFunc(app.doRevealSnippet))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.viewSnippet))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.viewRawSnippet))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(app.downloadSnippet))
+	router.Handler(http.MethodGet, "/snippet/search", dynamic.ThenFunc(app.searchSnippets))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.displaySignupPage))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.doSignupUser))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.displayLoginPage))
@@ -29,11 +36,35 @@ func (app *application) routes() http.Handler {
 
 	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.displayCreateSnippetPage))
 	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.doCreateSnippet))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(app.displayEditSnippetPage))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(app.doEditSnippet))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", protected.ThenFunc(app.doDeleteSnippet))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.doLogoutUser))
 	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.viewAccount))
+	router.Handler(http.MethodGet, "/account/snippets", protected.ThenFunc(app.viewUserSnippets))
 	router.Handler(http.MethodGet, "/account/change-password", protected.ThenFunc(app.displayChangeUserPasswordPage))
 	router.Handler(http.MethodPost, "/account/change-password", protected.ThenFunc(app.doUpdateUserPassword))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.viewAPITokens))
+	router.Handler(http.MethodPost, "/account/tokens", protected.ThenFunc(app.doCreateAPIToken))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", protected.ThenFunc(app.doRevokeAPIToken))
 
-	standard := alice.New(app.logRequest)
-	return standard.Then(router)
+	// The API doesn't check the CSRF token of the forms, preventAPICSRF requires
+	// the writes authenticated by the session to be sent as application/json
+	// instead. Besides the session, clients can authenticate with a personal API token.
+	api := alice.New(app.sessionManager.LoadAndSave, app.authenticate, app.authenticateToken)
+	apiRead := api.Append(app.requireScope(scopeSnippetsRead))
+	apiWrite := api.Append(app.requireAPIAuthentication, app.requireScope(scopeSnippetsWrite), app.preventAPICSRF)
+
+	router.Handler(http.MethodGet, "/api/v1/snippets", apiRead.ThenFunc(app.apiListSnippets))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", apiRead.ThenFunc(app.apiGetSnippet))
+	router.Handler(http.MethodPost, "/api/v1/snippets", apiWrite.ThenFunc(app.apiCreateSnippet))
+	router.Handler(http.MethodPut, "/api/v1/snippets/:id", apiWrite.ThenFunc(app.apiUpdateSnippet))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:id", apiWrite.ThenFunc(app.apiDeleteSnippet))
+
+	return app.standard().Then(router)
+}
+
+// standard returns the middlewares which wrap every request, the router included.
+func (app *application) standard() alice.Chain {
+	return alice.New(app.recoverPanic, app.logRequest, app.secureHeaders, app.limitRequestBody)
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+)
+
+// serve starts the HTTP server, or the HTTPS server (plus the optional
+// HTTP-to-HTTPS redirect server) when TLS is configured. On SIGINT or SIGTERM
+// it stops accepting connections, lets in-flight requests and background
+// goroutines finish, then returns nil.
+func (app *application) serve() error {
+	server := app.newServer(app.config.addr, app.routes())
+
+	var redirectServer *http.Server
+	if app.config.useTLS() && app.config.tls.redirectAddr != "" {
+		redirectServer = app.newServer(app.config.tls.redirectAddr, app.redirectToHTTPS())
+	}
+
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-quit
+
+		app.infoLog.Printf("Caught signal %s, shutting down server", sig)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+		defer cancel()
+
+		// Shutdown() closes the listeners, then waits for the in-flight requests
+		// to finish or for the timeout to be reached. Both servers are always
+		// shut down, so a failing one can't keep the other serving.
+		var redirectErr error
+		if redirectServer != nil {
+			redirectErr = redirectServer.Shutdown(ctx)
+		}
+
+		err := errors.Join(redirectErr, server.Shutdown(ctx))
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		app.infoLog.Print("Waiting for background tasks to finish")
+		app.wg.Wait()
+
+		shutdownError <- nil
+	}()
+
+	var err error
+
+	if app.config.useTLS() {
+		server.TLSConfig = newTLSConfig()
+
+		if redirectServer != nil {
+			app.background(func() {
+				app.infoLog.Printf("Redirecting HTTP requests on %s to HTTPS", redirectServer.Addr)
+
+				err := redirectServer.ListenAndServe()
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					app.errorLog.Print(err)
+				}
+			})
+		}
+
+		app.infoLog.Printf("Starting server on https://%s", server.Addr)
+		err = server.ListenAndServeTLS(app.config.tls.certFile, app.config.tls.keyFile)
+	} else {
+		app.infoLog.Printf("Starting server on http://%s", server.Addr)
+		err = server.ListenAndServe()
+	}
+
+	// ErrServerClosed means that a shutdown has started, any other error
+	// means the server couldn't start at all.
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.infoLog.Print("Stopped server")
+
+	return nil
+}
+
+// newServer returns an HTTP server with the configured timeouts and header size limit.
+func (app *application) newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ErrorLog:          app.errorLog,
+		ReadTimeout:       app.config.server.readTimeout,
+		ReadHeaderTimeout: app.config.server.readHeaderTimeout,
+		WriteTimeout:      app.config.server.writeTimeout,
+		IdleTimeout:       app.config.server.idleTimeout,
+		MaxHeaderBytes:    app.config.server.maxHeaderBytes,
+	}
+}
+
+// background runs fn in a goroutine which is waited for before shutting down.
+// A panic inside fn is logged instead of crashing the whole application.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Print(fmt.Errorf("%s\n%s", err, debug.Stack()))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// newTLSConfig returns a hardened TLS configuration: only TLS 1.2 and above,
+// curves with assembly implementations, and (for TLS 1.2) only cipher suites
+// with forward secrecy and authenticated encryption. TLS 1.3 cipher suites
+// are not configurable and are all safe.
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		},
+	}
+}
+
+// redirectToHTTPS returns a handler which permanently redirects every request
+// to the same host and path on the HTTPS server.
+func (app *application) redirectToHTTPS() http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(app.config.addr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			// The Host header has no port.
+			host = r.Host
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
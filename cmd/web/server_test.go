@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		host    string
+		target  string
+		wantURL string
+	}{
+		{
+			name:    "HTTPS port",
+			addr:    "127.0.0.1:4000",
+			host:    "example.com:8080",
+			target:  "/snippet/view/1?page=2",
+			wantURL: "https://example.com:4000/snippet/view/1?page=2",
+		},
+		{
+			name:    "Default HTTPS port",
+			addr:    ":443",
+			host:    "example.com:80",
+			target:  "/s/abc/raw?download=1",
+			wantURL: "https://example.com/s/abc/raw?download=1",
+		},
+		{
+			name:    "Host without port",
+			addr:    ":4000",
+			host:    "example.com",
+			target:  "/",
+			wantURL: "https://example.com:4000/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.addr = tt.addr
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Host = tt.host
+
+			app.redirectToHTTPS().ServeHTTP(rr, r)
+
+			if rr.Code != http.StatusMovedPermanently {
+				t.Errorf("got status %d; want %d", rr.Code, http.StatusMovedPermanently)
+			}
+
+			if got := rr.Header().Get("Location"); got != tt.wantURL {
+				t.Errorf("got Location %q; want %q", got, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	// The test server serves its own self-signed certificate.
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = newTLSConfig()
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0) // the refused handshakes are logged
+	ts.StartTLS()
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		version uint16
+		wantErr bool
+	}{
+		{"TLS 1.1", tls.VersionTLS11, true},
+		{"TLS 1.2", tls.VersionTLS12, false},
+		{"TLS 1.3", tls.VersionTLS13, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), &tls.Config{
+				InsecureSkipVerify: true,
+				MinVersion:         tt.version,
+				MaxVersion:         tt.version,
+			})
+			if err == nil {
+				conn.Close()
+			}
+
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Errorf("got handshake error %v; want an error: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// staticFiles serves the files under /static/. Unlike http.FileServer it never
+// lists directories, it serves the precompressed ".br" or ".gz" variant of a
+// file when the client accepts it, and it lets browsers cache a file forever
+// when the URL carries the hash of its content.
+type staticFiles struct {
+	fsys fs.FS
+
+	// cache is false when the files may change while running (development
+	// mode), then the files are checked again every time.
+	cache bool
+	mu    sync.Mutex
+	files map[string]staticFile
+}
+
+// staticFile holds the hash of the content of a file, and the names of the
+// encodings whose precompressed variant matches that content.
+type staticFile struct {
+	hash      string
+	encodings map[string]bool
+}
+
+// encodings are the precompressed variants looked for, in order of preference.
+// The variants are made by "make compress-static" and may be left over from an
+// older version of a file, so matches tells whether a variant was compressed
+// from the current content.
+var encodings = []struct {
+	name      string
+	extension string
+	matches   func(fsys fs.FS, variant string, content []byte) bool
+}{
+	{"br", ".br", brotliMatches},
+	{"gzip", ".gz", gzipMatches},
+}
+
+func newStaticFiles(fsys fs.FS, cache bool) *staticFiles {
+	return &staticFiles{
+		fsys:  fsys,
+		cache: cache,
+		files: make(map[string]staticFile),
+	}
+}
+
+// templateFuncs returns the template functions which need the static files.
+func (s *staticFiles) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"static": s.url,
+	}
+}
+
+// url returns the fingerprinted URL of a static file, like
+// "/static/css/main.css?v=0123456789ab". A new version of the file gets a new URL,
+// so it can be cached forever. It is used in templates as {{static "css/main.css"}}.
+func (s *staticFiles) url(name string) (string, error) {
+	file, err := s.file(name)
+	if err != nil {
+		return "", err
+	}
+
+	return "/static/" + name + "?v=" + file.hash, nil
+}
+
+// file returns the hash of a file, which is the first 12 hexadecimal digits of
+// the SHA-256 of its content, and its matching precompressed variants.
+func (s *staticFiles) file(name string) (staticFile, error) {
+	if s.cache {
+		s.mu.Lock()
+		file, ok := s.files[name]
+		s.mu.Unlock()
+
+		if ok {
+			return file, nil
+		}
+	}
+
+	content, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return staticFile{}, err
+	}
+
+	sum := sha256.Sum256(content)
+	file := staticFile{
+		hash:      hex.EncodeToString(sum[:])[:12],
+		encodings: make(map[string]bool),
+	}
+
+	for _, encoding := range encodings {
+		if encoding.matches(s.fsys, name+encoding.extension, content) {
+			file.encodings[encoding.name] = true
+		}
+	}
+
+	if s.cache {
+		s.mu.Lock()
+		s.files[name] = file
+		s.mu.Unlock()
+	}
+
+	return file, nil
+}
+
+// gzipMatches reports whether the gzip variant exists and decompresses to the content.
+func gzipMatches(fsys fs.FS, variant string, content []byte) bool {
+	f, err := fsys.Open(variant)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return false
+	}
+
+	// Reading one byte more than the content is enough to tell a longer file apart.
+	decompressed, err := io.ReadAll(io.LimitReader(zr, int64(len(content))+1))
+	return err == nil && bytes.Equal(decompressed, content)
+}
+
+// brotliMatches reports whether the brotli variant exists and was compressed
+// from the content. The standard library has no brotli decoder, so it relies
+// on the ".sha256" file which "make compress-static" writes next to the
+// variant, holding the SHA-256 of the content it was compressed from.
+func brotliMatches(fsys fs.FS, variant string, content []byte) bool {
+	if _, err := fs.Stat(fsys, variant); err != nil {
+		return false
+	}
+
+	recorded, err := fs.ReadFile(fsys, variant+".sha256")
+	if err != nil {
+		return false
+	}
+
+	sum := sha256.Sum256(content)
+	return strings.TrimSpace(string(recorded)) == hex.EncodeToString(sum[:])
+}
+
+// ServeHTTP serves the file named by the URL path, which must already have
+// the "/static/" prefix stripped.
+func (s *staticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	static, err := s.file(name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("v") == static.hash {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		// The URL isn't fingerprinted (or is outdated), so the browser must
+		// check with the ETag whether its copy is still fresh.
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	// The response depends on Accept-Encoding, so caches must store a copy per encoding.
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	served := name
+	etag := static.hash
+
+	for _, encoding := range encodings {
+		if static.encodings[encoding.name] && acceptsEncoding(r, encoding.name) {
+			served = name + encoding.extension
+			etag = static.hash + "-" + encoding.name
+			w.Header().Set("Content-Encoding", encoding.name)
+			break
+		}
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+
+	file, err := s.fsys.Open(served)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err = file.Stat()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	// ServeContent picks the Content-Type from the extension of the original
+	// name, and answers conditional requests using the ETag. The embedded
+	// files have a zero modification time, which means none.
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of the request
+// allows the content coding, ignoring the ones explicitly refused with "q=0".
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			if !strings.EqualFold(strings.TrimSpace(name), coding) {
+				continue
+			}
+
+			weight, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !ok {
+				return true
+			}
+
+			q, err := strconv.ParseFloat(weight, 64)
+			return err == nil && q > 0
+		}
+	}
+
+	return false
+}
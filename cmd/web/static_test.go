@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticFilesVariants(t *testing.T) {
+	content := []byte("body { color: red; }")
+	stale := []byte("body { color: blue; }")
+
+	fsys := fstest.MapFS{
+		"fresh.css":           {Data: content},
+		"fresh.css.gz":        {Data: gzipped(t, content)},
+		"fresh.css.br":        {Data: []byte("brotli")},
+		"fresh.css.br.sha256": {Data: []byte(sha256Hex(content) + "\n")},
+		"stale.css":           {Data: content},
+		"stale.css.gz":        {Data: gzipped(t, stale)},
+		"stale.css.br":        {Data: []byte("brotli")},
+		"stale.css.br.sha256": {Data: []byte(sha256Hex(stale) + "\n")},
+		"plain.css":           {Data: content},
+	}
+
+	tests := []struct {
+		name           string
+		file           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"Brotli preferred", "fresh.css", "gzip, br", "br"},
+		{"Gzip", "fresh.css", "gzip", "gzip"},
+		{"Nothing accepted", "fresh.css", "", ""},
+		{"Stale brotli", "stale.css", "br", ""},
+		{"Stale gzip", "stale.css", "gzip", ""},
+		{"No variants", "plain.css", "gzip, br", ""},
+	}
+
+	static := newStaticFiles(fsys, true)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.file, nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			static.ServeHTTP(rr, r)
+
+			if got := rr.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("got Content-Encoding %q; want %q", got, tt.wantEncoding)
+			}
+
+			if tt.wantEncoding == "" && !bytes.Equal(rr.Body.Bytes(), content) {
+				t.Errorf("got body %q; want %q", rr.Body.Bytes(), content)
+			}
+		})
+	}
+}
+
+func gzipped(t *testing.T, content []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
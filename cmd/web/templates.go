@@ -3,26 +3,81 @@ package main
 import (
 	"github.com/chauvinhphuoc/snippetbox/internal/db/sqlc"
 	"html/template"
+	"io/fs"
 	"net/http"
-	"path/filepath"
+	"path"
 	"time"
 )
 
 // functionTemplates contains all baked-in functions which integrated in every template set.
 var functionTemplates = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"isExpired":     isExpired,
+	"expiresIn":     expiresIn,
+	"highlight":     highlight,
+	"highlightCode": highlightCode,
+	"languages":     func() []language { return languages },
+	"formats":       func() []format { return formats },
+	"visibilities":  func() []visibility { return visibilities },
+	"expiresUnits":  func() []expiresUnit { return expiresUnits },
+	"apiScopes":     func() []apiScope { return apiScopes },
+	"markdown":      markdown,
 }
 
 // templateData acts as the holding structure for any dynamic data
 // that we want to pass to our HTML templates.
 type templateData struct {
-	CurrentYear     int                 // used for printing current year
-	Snippet         sqlc.Snippet        // used for view snippet page
-	Snippets        []sqlc.Snippet      // used for home page
-	Form            any                 // used for any HTML form
-	Flash           string              // used for flash messages
-	IsAuthenticated bool                // used for hidden information from unauthenticated user
-	User            sqlc.GetUserByIDRow // used for account page
+	CurrentYear     int                           // used for printing current year
+	Snippet         sqlc.GetSnippetNotExpiredRow  // used for view snippet page
+	Snippets        []sqlc.ListSnippetsBeforeRow  // used for home page
+	Form            any                           // used for any HTML form
+	Flash           string                        // used for flash messages
+	csrfToken       func() (string, error)        // used by CSRFToken
+	CSPNonce        string                        // used for the nonce attribute of inline scripts
+	IsAuthenticated bool                          // used for hidden information from unauthenticated user
+	UserID          int                           // used for showing actions only to the owner of a snippet
+	User            sqlc.GetUserByIDRow           // used for account page
+	UserSnippets    []sqlc.Snippet                // used for "My snippets" page
+	APITokens       []sqlc.ListAPITokensByUserRow // used for API tokens page
+	NewAPIToken     string                        // used for showing a new API token once
+	Burnt           bool                          // used for the only view of a snippet deleted once read
+	Query           string                        // used for the search box
+	SearchResults   []sqlc.SearchSnippetsRow      // used for search page
+	Page            pageInfo                      // used for page navigation links
+	Cursor          cursorInfo                    // used for page navigation links of the home page
+	Error           errorPage                     // used for error page
+}
+
+// CSRFToken returns the token for the hidden CSRF field of a form. It is only
+// created when a page with a form is rendered, so the other pages don't store a
+// session for every anonymous visitor.
+func (data *templateData) CSRFToken() (string, error) {
+	return data.csrfToken()
+}
+
+// cursorInfo holds the keyset cursors of the pages around the current one:
+// Older is used as "?before=" and Newer as "?after=". They are zero when
+// there is no such page.
+type cursorInfo struct {
+	Older int32
+	Newer int32
+}
+
+// errorPage holds what the error page shows about a failed request.
+type errorPage struct {
+	Status  int
+	Title   string
+	Message string
+}
+
+// pageInfo holds the numbers of the current, previous and next pages of a list.
+// Previous and Next are zero when there is no such page. Query is the search
+// query, which the links must keep.
+type pageInfo struct {
+	Current  int
+	Previous int
+	Next     int
+	Query    string
 }
 
 // newTemplateData returns a *templateData, which contains some fields having default values.
@@ -30,50 +85,67 @@ func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
+		csrfToken:       func() (string, error) { return app.csrfToken(r) },
+		CSPNonce:        cspNonce(r),
 		IsAuthenticated: app.isAuthenticated(r),
+		UserID:          app.authenticatedUserID(r),
 	}
 }
 
-// initializeTemplateCache parses all template files once when application is starting running,
-// and storing those parsed template in an in-memory cache.
-func initializeTemplateCache() (map[string]*template.Template, error) {
+// initializeTemplateCache parses all template files inside fsys once when application is starting running,
+// and storing those parsed template in an in-memory cache. funcs are template functions added to
+// functionTemplates, which need the state of the application.
+func initializeTemplateCache(fsys fs.FS, funcs template.FuncMap) (map[string]*template.Template, error) {
 	caches := make(map[string]*template.Template)
 
-	// Get all relative file paths inside "pages" directory
-	pages, err := filepath.Glob("./ui/html/pages/*.html")
+	// Get all file paths inside "pages" directory
+	pages, err := fs.Glob(fsys, "pages/*.html")
 	if err != nil {
 		return nil, err
 	}
 
 	for _, page := range pages {
-		// Extract the file name from the full file path
-		name := filepath.Base(page)
-
-		// The template.FuncMap must be registered with the template set before you
-		// call the ParseFiles() method. This means we have to use template.New() to
-		// create an empty template set, use the Funcs() method to register the
-		// template.FuncMap, and then parse the file as normal.
-		ts, err := template.New(name).Funcs(functionTemplates).ParseFiles("./ui/html/base.html")
-		if err != nil {
-			return nil, err
-		}
-
-		// Call ParseGlob() on ts to add any partials.
-		ts, err = ts.ParseGlob("./ui/html/partials/*.html")
-		if err != nil {
-			return nil, err
-		}
-
-		// Call ParseFiles() on ts to add the page template.
-		ts, err = ts.ParseFiles(page)
+		ts, err := parseTemplateSet(fsys, page, funcs)
 		if err != nil {
 			return nil, err
 		}
 
 		// Add the template set to the map, using the name of the page
 		// (like 'home.html') as the key
-		caches[name] = ts
+		caches[path.Base(page)] = ts
 	}
 
 	return caches, nil
 }
+
+// parseTemplateSet parses the template set of one page: the base layout,
+// all partials and the page itself.
+func parseTemplateSet(fsys fs.FS, page string, funcs template.FuncMap) (*template.Template, error) {
+	patterns := []string{
+		"base.html",
+		"partials/*.html",
+		page,
+	}
+
+	// The template.FuncMap must be registered with the template set before you
+	// call the ParseFS() method. This means we have to use template.New() to
+	// create an empty template set, use the Funcs() method to register the
+	// template.FuncMap, and then parse the files as normal.
+	return template.New(path.Base(page)).Funcs(functionTemplates).Funcs(funcs).ParseFS(fsys, patterns...)
+}
+
+// devErrorTemplate shows a template error in the browser in development mode.
+// It doesn't depend on the template files, as they may be the broken ones.
+var devErrorTemplate = template.Must(template.New("devError").Parse(`<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Template error - Snippetbox</title>
+</head>
+<body style="font-family: monospace; margin: 2em;">
+<h1 style="color: #C0392B;">Template error</h1>
+<pre style="white-space: pre-wrap; padding: 1em; background: #F7F9FA; border: 1px solid #E4E5E7;">{{.}}</pre>
+<p>Fix the template file and reload the page.</p>
+</body>
+</html>
+`))
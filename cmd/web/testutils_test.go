@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+// newTestApplication returns an application with the default settings, which
+// ignores the config file and environment variables, and loggers which discard
+// their output. It has no database, so it only suits handlers and middlewares
+// which don't query one.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	var cfg config
+	newFlagSet(&cfg)
+
+	err := cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &application{
+		config:   cfg,
+		infoLog:  log.New(io.Discard, "", 0),
+		errorLog: log.New(io.Discard, "", 0),
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// attemptLimiter throttles attempts which can be guessed, like snippet
+// passwords: each key may make max attempts per window, which starts with the
+// first attempt. The attempts are only kept in memory, so they are forgotten
+// on restart and aren't shared between several servers.
+type attemptLimiter struct {
+	max    int
+	window time.Duration
+
+	mu        sync.Mutex
+	attempts  map[string]attemptWindow
+	lastSweep time.Time
+}
+
+// attemptWindow counts the attempts of a key since start.
+type attemptWindow struct {
+	count int
+	start time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		attempts: make(map[string]attemptWindow),
+	}
+}
+
+// allow records an attempt of the key and returns true, or returns false with
+// the time left until the key may try again when it has none left. The attempt
+// is counted before it is checked, so concurrent requests can't exceed max.
+func (l *attemptLimiter) allow(key string) (retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	attempt, found := l.attempts[key]
+	if !found || now.Sub(attempt.start) >= l.window {
+		attempt = attemptWindow{start: now}
+	}
+
+	if attempt.count >= l.max {
+		return attempt.start.Add(l.window).Sub(now), false
+	}
+
+	attempt.count++
+	l.attempts[key] = attempt
+
+	return 0, true
+}
+
+// reset forgets the attempts of the key, after one of them succeeded.
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// sweep forgets the windows which have ended, at most once per window so that
+// allow stays cheap. The lock must be held.
+func (l *attemptLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+
+	for key, attempt := range l.attempts {
+		if now.Sub(attempt.start) >= l.window {
+			delete(l.attempts, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+// clientIP returns the IP address of the client which sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,19 @@
+package main
+
+// visibility is who can find and read a snippet. Name is the value stored in the database.
+type visibility struct {
+	Name  string
+	Label string
+}
+
+func (v visibility) optionName() string { return v.Name }
+
+// defaultVisibility is the visibility of snippets listed on the home page and in the search.
+const defaultVisibility = "public"
+
+// visibilities lists the visibilities offered by the snippet forms, in the order they are shown.
+var visibilities = []visibility{
+	{defaultVisibility, "Public, listed on the home page"},
+	{"unlisted", "Unlisted, only reachable by its link"},
+	{"private", "Private, only visible to you"},
+}
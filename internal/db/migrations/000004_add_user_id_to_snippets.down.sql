@@ -0,0 +1,5 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS user_id;
+
+DELETE
+FROM users
+WHERE email = 'anonymous@snippetbox.invalid';
\ No newline at end of file
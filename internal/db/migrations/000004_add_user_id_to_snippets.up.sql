@@ -0,0 +1,22 @@
+-- Snippets created before ownership was tracked are kept and attributed to an
+-- "Anonymous" user, which is only created when there are such snippets. Its
+-- password hash is of a random password which was thrown away, so nobody can
+-- log in as it.
+ALTER TABLE snippets
+    ADD COLUMN user_id INTEGER REFERENCES users (id) ON DELETE CASCADE;
+
+INSERT INTO users (name, email, hashed_password, created_at)
+SELECT 'Anonymous',
+       'anonymous@snippetbox.invalid',
+       '$2a$12$UutokjUFVGZreWuosG1CzOFK8CtClZI7RnhCknLeauKZD8jeLKNEq',
+       CURRENT_TIMESTAMP
+WHERE EXISTS (SELECT 1 FROM snippets);
+
+UPDATE snippets
+SET user_id = (SELECT id FROM users WHERE email = 'anonymous@snippetbox.invalid')
+WHERE user_id IS NULL;
+
+ALTER TABLE snippets
+    ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX ON snippets (user_id);
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- The search queries must use this exact expression for the index to be used.
+-- Titles weigh more than content when ranking the results.
+CREATE INDEX snippets_search_idx ON snippets
+    USING GIN ((setweight(to_tsvector('english', title), 'A') ||
+                setweight(to_tsvector('english', content), 'B')));
\ No newline at end of file
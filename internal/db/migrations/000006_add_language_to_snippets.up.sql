@@ -0,0 +1,3 @@
+-- Existing snippets have no known language, so they stay plain text.
+ALTER TABLE snippets
+    ADD COLUMN language TEXT NOT NULL DEFAULT 'plaintext';
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS format;
\ No newline at end of file
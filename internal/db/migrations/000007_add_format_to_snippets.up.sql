@@ -0,0 +1,7 @@
+ALTER TABLE snippets
+    ADD COLUMN format TEXT NOT NULL DEFAULT 'plain' CHECK (format IN ('plain', 'code', 'markdown'));
+
+-- Snippets which were given a language are code.
+UPDATE snippets
+SET format = 'code'
+WHERE language <> 'plaintext';
\ No newline at end of file
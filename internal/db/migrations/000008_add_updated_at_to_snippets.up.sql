@@ -0,0 +1,6 @@
+ALTER TABLE snippets
+    ADD COLUMN updated_at timestamptz NOT NULL DEFAULT NOW();
+
+-- Snippets created before this column existed are assumed never edited.
+UPDATE snippets
+SET updated_at = created_at;
\ No newline at end of file
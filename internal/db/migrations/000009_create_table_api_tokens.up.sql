@@ -0,0 +1,15 @@
+-- Only the SHA-256 hash of each token is stored, the token itself is shown
+-- once to the user when it is created.
+CREATE TABLE api_tokens
+(
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         VARCHAR(100) NOT NULL,
+    hash         BYTEA        NOT NULL UNIQUE,
+    scopes       TEXT[]       NOT NULL,
+    created_at   timestamptz  NOT NULL DEFAULT NOW(),
+    last_used_at timestamptz,
+    expires      timestamptz  NOT NULL
+);
+
+CREATE INDEX ON api_tokens (user_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE snippets
+    ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'unlisted', 'private'));
\ No newline at end of file
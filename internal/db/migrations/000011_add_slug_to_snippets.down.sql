@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS slug;
\ No newline at end of file
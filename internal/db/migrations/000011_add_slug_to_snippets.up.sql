@@ -0,0 +1,11 @@
+ALTER TABLE snippets
+    ADD COLUMN slug TEXT;
+
+-- Existing snippets get a slug like the application generates: 16 random
+-- bytes, as unpadded base64url.
+UPDATE snippets
+SET slug = translate(rtrim(encode(uuid_send(gen_random_uuid()), 'base64'), '='), '+/', '-_');
+
+ALTER TABLE snippets
+    ALTER COLUMN slug SET NOT NULL,
+    ADD CONSTRAINT snippets_slug_key UNIQUE (slug);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS hashed_password;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- The bcrypt hash of the password protecting the snippet, NULL when it has none.
+ALTER TABLE snippets
+    ADD COLUMN hashed_password CHAR(60);
\ No newline at end of file
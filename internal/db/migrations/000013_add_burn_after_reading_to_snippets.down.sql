@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN IF EXISTS burn_after_reading;
\ No newline at end of file
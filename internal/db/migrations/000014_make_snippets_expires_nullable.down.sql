@@ -0,0 +1,7 @@
+-- Snippets which never expired are kept for a hundred years instead.
+UPDATE snippets
+SET expires = created_at + INTERVAL '100 years'
+WHERE expires IS NULL;
+
+ALTER TABLE snippets
+    ALTER COLUMN expires SET NOT NULL;
\ No newline at end of file
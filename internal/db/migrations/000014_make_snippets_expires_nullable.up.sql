@@ -0,0 +1,3 @@
+-- Snippets which never expire have a NULL expiry.
+ALTER TABLE snippets
+    ALTER COLUMN expires DROP NOT NULL;
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- name: CreateAPIToken :exec
+INSERT INTO api_tokens (user_id, name, hash, scopes, created_at, expires)
+VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + MAKE_INTERVAL(DAYS => sqlc.arg(duration)::int));
+
+-- name: GetAPITokenByHash :one
+SELECT id, user_id, scopes
+FROM api_tokens
+WHERE hash = $1
+  AND expires > CURRENT_TIMESTAMP;
+
+-- name: ListAPITokensByUser :many
+SELECT id, name, scopes, created_at, last_used_at, expires
+FROM api_tokens
+WHERE user_id = $1
+ORDER BY id DESC;
+
+-- name: UpdateAPITokenLastUsed :exec
+UPDATE api_tokens
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: DeleteAPIToken :execrows
+DELETE
+FROM api_tokens
+WHERE id = $1
+  AND user_id = $2;
\ No newline at end of file
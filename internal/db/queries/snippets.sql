@@ -1,15 +1,111 @@
 -- name: CreateSnippet :one
-INSERT INTO snippets (title, content, created_at, expires)
-VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + MAKE_INTERVAL(DAYS => sqlc.arg(duration)::int)) RETURNING id;
+-- A NULL expiry means that the snippet never expires.
+INSERT INTO snippets (title, content, created_at, updated_at, expires, user_id, language, format, visibility, slug,
+                      hashed_password, burn_after_reading)
+VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, sqlc.narg(expires), sqlc.arg(user_id), sqlc.arg(language),
+        sqlc.arg(format), sqlc.arg(visibility), sqlc.arg(slug), sqlc.arg(hashed_password),
+        sqlc.arg(burn_after_reading)) RETURNING id;
 
 -- name: GetSnippetNotExpired :one
+-- IDs can be counted, so only public snippets are returned by ID, except to
+-- their owner. The viewer ID is 0 for anonymous users.
+SELECT snippets.*, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.id = sqlc.arg(id)
+  AND (snippets.visibility = 'public' OR snippets.user_id = sqlc.arg(viewer_id));
+
+-- name: GetSnippetBySlug :one
+-- Private snippets are only returned to their owner, the viewer ID is 0 for
+-- anonymous users.
+SELECT snippets.*, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.slug = sqlc.arg(slug)
+  AND (snippets.visibility <> 'private' OR snippets.user_id = sqlc.arg(viewer_id));
+
+-- name: ListSnippetsBefore :many
+SELECT snippets.*, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.visibility = 'public'
+  AND snippets.id < sqlc.arg(before)
+ORDER BY snippets.id DESC LIMIT sqlc.arg(page_size);
+
+-- name: ListSnippetsAfter :many
+SELECT snippets.*, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.visibility = 'public'
+  AND snippets.id > sqlc.arg(after)
+ORDER BY snippets.id ASC LIMIT sqlc.arg(page_size);
+
+-- name: GetSnippetForBurn :one
+-- The row stays locked until the end of the transaction, so a concurrent
+-- reader waits and then finds the snippet deleted.
+SELECT snippets.*, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.id = $1
+    FOR UPDATE OF snippets;
+
+-- name: GetSnippet :one
 SELECT *
 FROM snippets
-WHERE expires > CURRENT_TIMESTAMP
-  AND id = $1;
+WHERE id = $1;
 
--- name: GetTenLatestSnippets :many
+-- name: UpdateSnippet :exec
+UPDATE snippets
+SET title              = $1,
+    content            = $2,
+    language           = $3,
+    format             = $4,
+    visibility         = $5,
+    hashed_password    = $6,
+    burn_after_reading = $7,
+    expires            = sqlc.narg(expires),
+    updated_at         = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id);
+
+-- name: DeleteSnippet :exec
+DELETE
+FROM snippets
+WHERE id = $1;
+
+-- name: ListSnippetsByUser :many
 SELECT *
 FROM snippets
-WHERE expires > CURRENT_TIMESTAMP
-ORDER BY id DESC LIMIT 10;
+WHERE user_id = $1
+ORDER BY id DESC LIMIT $2 OFFSET $3;
+
+-- name: SearchSnippets :many
+-- The headline marks the matched words with the STX and ETX control
+-- characters, which can't be confused with HTML in the content. Snippets
+-- protected by a password or deleted once read are left out, since their
+-- content is matched.
+SELECT snippets.id,
+       snippets.slug,
+       snippets.title,
+       snippets.created_at,
+       users.name AS author,
+       ts_headline('english', snippets.content, websearch_to_tsquery('english', sqlc.arg(query)),
+                   'StartSel=' || CHR(2) || ', StopSel=' || CHR(3) ||
+                   ', MaxFragments=2, MaxWords=20, MinWords=8')::text AS headline
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.visibility = 'public'
+  AND snippets.hashed_password IS NULL
+  AND NOT snippets.burn_after_reading
+  AND (setweight(to_tsvector('english', snippets.title), 'A') ||
+       setweight(to_tsvector('english', snippets.content), 'B')) @@ websearch_to_tsquery('english', sqlc.arg(query))
+ORDER BY ts_rank(setweight(to_tsvector('english', snippets.title), 'A') ||
+                 setweight(to_tsvector('english', snippets.content), 'B'),
+                 websearch_to_tsquery('english', sqlc.arg(query))) DESC,
+         snippets.id DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
\ No newline at end of file
@@ -1,17 +1,31 @@
-INSERT INTO snippets (title, content, created_at, expires)
+-- The password of the sample user is "pa55word". The slugs are generated like in
+-- the migration which added them.
+INSERT INTO users (name, email, hashed_password, created_at)
+VALUES ('Alice Jones',
+        'alice@example.com',
+        '$2a$12$9cJ3NLlnMiyTKKro9zCmkOkFryW97P3O301LNrMU8SYNYc/Zqrw4y',
+        CURRENT_TIMESTAMP);
+
+INSERT INTO snippets (title, content, created_at, expires, user_id, slug)
 VALUES ('An old silent pond',
         E'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n\n– Matsuo Bashō',
         CURRENT_TIMESTAMP,
-        CURRENT_TIMESTAMP + INTERVAL '10 seconds');
+        CURRENT_TIMESTAMP + INTERVAL '10 seconds',
+        (SELECT id FROM users WHERE email = 'alice@example.com'),
+        translate(rtrim(encode(uuid_send(gen_random_uuid()), 'base64'), '='), '+/', '-_'));
 
-INSERT INTO snippets (title, content, created_at, expires)
+INSERT INTO snippets (title, content, created_at, expires, user_id, slug)
 VALUES ('Over the wintry forest',
         E'Over the wintry\nforest, winds howl in rage\nwith no leaves to blow.\n\n– Natsume Soseki',
         CURRENT_TIMESTAMP,
-        CURRENT_TIMESTAMP + INTERVAL '1 days');
+        CURRENT_TIMESTAMP + INTERVAL '1 days',
+        (SELECT id FROM users WHERE email = 'alice@example.com'),
+        translate(rtrim(encode(uuid_send(gen_random_uuid()), 'base64'), '='), '+/', '-_'));
 
-INSERT INTO snippets (title, content, created_at, expires)
+INSERT INTO snippets (title, content, created_at, expires, user_id, slug)
 VALUES ('First autumn morning',
         E'First autumn morning\nthe mirror I stare into\nshows my father''s face.\n\n– Murakami Kijo',
         CURRENT_TIMESTAMP,
-        CURRENT_TIMESTAMP + INTERVAL '7 days');
\ No newline at end of file
+        CURRENT_TIMESTAMP + INTERVAL '7 days',
+        (SELECT id FROM users WHERE email = 'alice@example.com'),
+        translate(rtrim(encode(uuid_send(gen_random_uuid()), 'base64'), '='), '+/', '-_'));
\ No newline at end of file
@@ -5,9 +5,21 @@
 package sqlc
 
 import (
+	"database/sql"
 	"time"
 )
 
+type ApiToken struct {
+	ID         int32        `json:"id"`
+	UserID     int32        `json:"user_id"`
+	Name       string       `json:"name"`
+	Hash       []byte       `json:"hash"`
+	Scopes     []string     `json:"scopes"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	Expires    time.Time    `json:"expires"`
+}
+
 type Session struct {
 	Token  string    `json:"token"`
 	Data   []byte    `json:"data"`
@@ -15,11 +27,19 @@ type Session struct {
 }
 
 type Snippet struct {
-	ID        int32     `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	Expires   time.Time `json:"expires"`
+	ID               int32          `json:"id"`
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Expires          *time.Time     `json:"expires"`
+	UserID           int32          `json:"user_id"`
+	Language         string         `json:"language"`
+	Format           string         `json:"format"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Visibility       string         `json:"visibility"`
+	Slug             string         `json:"slug"`
+	HashedPassword   sql.NullString `json:"-"`
+	BurnAfterReading bool           `json:"burn_after_reading"`
 }
 
 type User struct {
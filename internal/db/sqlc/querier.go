@@ -9,14 +9,38 @@ import (
 )
 
 type Querier interface {
+	CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) error
+	// A NULL expiry means that the snippet never expires.
 	CreateSnippet(ctx context.Context, arg CreateSnippetParams) (int32, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) error
+	DeleteAPIToken(ctx context.Context, arg DeleteAPITokenParams) (int64, error)
+	DeleteSnippet(ctx context.Context, id int32) error
+	GetAPITokenByHash(ctx context.Context, hash []byte) (GetAPITokenByHashRow, error)
 	GetPasswordByID(ctx context.Context, id int32) (string, error)
-	GetSnippetNotExpired(ctx context.Context, id int32) (Snippet, error)
-	GetTenLatestSnippets(ctx context.Context) ([]Snippet, error)
+	GetSnippet(ctx context.Context, id int32) (Snippet, error)
+	// Private snippets are only returned to their owner, the viewer ID is 0 for
+	// anonymous users.
+	GetSnippetBySlug(ctx context.Context, arg GetSnippetBySlugParams) (GetSnippetBySlugRow, error)
+	// The row stays locked until the end of the transaction, so a concurrent
+	// reader waits and then finds the snippet deleted.
+	GetSnippetForBurn(ctx context.Context, id int32) (GetSnippetForBurnRow, error)
+	// IDs can be counted, so only public snippets are returned by ID, except to
+	// their owner. The viewer ID is 0 for anonymous users.
+	GetSnippetNotExpired(ctx context.Context, arg GetSnippetNotExpiredParams) (GetSnippetNotExpiredRow, error)
 	GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error)
 	GetUserByID(ctx context.Context, id int32) (GetUserByIDRow, error)
 	IsUserExist(ctx context.Context, id int32) (bool, error)
+	ListAPITokensByUser(ctx context.Context, userID int32) ([]ListAPITokensByUserRow, error)
+	ListSnippetsAfter(ctx context.Context, arg ListSnippetsAfterParams) ([]ListSnippetsAfterRow, error)
+	ListSnippetsBefore(ctx context.Context, arg ListSnippetsBeforeParams) ([]ListSnippetsBeforeRow, error)
+	ListSnippetsByUser(ctx context.Context, arg ListSnippetsByUserParams) ([]Snippet, error)
+	// The headline marks the matched words with the STX and ETX control
+	// characters, which can't be confused with HTML in the content. Snippets
+	// protected by a password or deleted once read are left out, since their
+	// content is matched.
+	SearchSnippets(ctx context.Context, arg SearchSnippetsParams) ([]SearchSnippetsRow, error)
+	UpdateAPITokenLastUsed(ctx context.Context, id int32) error
+	UpdateSnippet(ctx context.Context, arg UpdateSnippetParams) error
 	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
 }
 
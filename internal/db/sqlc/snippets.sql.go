@@ -7,35 +7,69 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const createSnippet = `-- name: CreateSnippet :one
-INSERT INTO snippets (title, content, created_at, expires)
-VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + MAKE_INTERVAL(DAYS => $3::int)) RETURNING id
+INSERT INTO snippets (title, content, created_at, updated_at, expires, user_id, language, format, visibility, slug,
+                      hashed_password, burn_after_reading)
+VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $3, $4, $5,
+        $6, $7, $8, $9,
+        $10) RETURNING id
 `
 
 type CreateSnippetParams struct {
-	Title    string `json:"title"`
-	Content  string `json:"content"`
-	Duration int32  `json:"duration"`
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	Expires          *time.Time     `json:"expires"`
+	UserID           int32          `json:"user_id"`
+	Language         string         `json:"language"`
+	Format           string         `json:"format"`
+	Visibility       string         `json:"visibility"`
+	Slug             string         `json:"slug"`
+	HashedPassword   sql.NullString `json:"-"`
+	BurnAfterReading bool           `json:"burn_after_reading"`
 }
 
+// A NULL expiry means that the snippet never expires.
 func (q *Queries) CreateSnippet(ctx context.Context, arg CreateSnippetParams) (int32, error) {
-	row := q.db.QueryRowContext(ctx, createSnippet, arg.Title, arg.Content, arg.Duration)
+	row := q.db.QueryRowContext(ctx, createSnippet,
+		arg.Title,
+		arg.Content,
+		arg.Expires,
+		arg.UserID,
+		arg.Language,
+		arg.Format,
+		arg.Visibility,
+		arg.Slug,
+		arg.HashedPassword,
+		arg.BurnAfterReading,
+	)
 	var id int32
 	err := row.Scan(&id)
 	return id, err
 }
 
-const getSnippetNotExpired = `-- name: GetSnippetNotExpired :one
-SELECT id, title, content, created_at, expires
+const deleteSnippet = `-- name: DeleteSnippet :exec
+DELETE
+FROM snippets
+WHERE id = $1
+`
+
+func (q *Queries) DeleteSnippet(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, deleteSnippet, id)
+	return err
+}
+
+const getSnippet = `-- name: GetSnippet :one
+SELECT id, title, content, created_at, expires, user_id, language, format, updated_at, visibility, slug, hashed_password, burn_after_reading
 FROM snippets
-WHERE expires > CURRENT_TIMESTAMP
-  AND id = $1
+WHERE id = $1
 `
 
-func (q *Queries) GetSnippetNotExpired(ctx context.Context, id int32) (Snippet, error) {
-	row := q.db.QueryRowContext(ctx, getSnippetNotExpired, id)
+func (q *Queries) GetSnippet(ctx context.Context, id int32) (Snippet, error) {
+	row := q.db.QueryRowContext(ctx, getSnippet, id)
 	var i Snippet
 	err := row.Scan(
 		&i.ID,
@@ -43,19 +77,333 @@ func (q *Queries) GetSnippetNotExpired(ctx context.Context, id int32) (Snippet,
 		&i.Content,
 		&i.CreatedAt,
 		&i.Expires,
+		&i.UserID,
+		&i.Language,
+		&i.Format,
+		&i.UpdatedAt,
+		&i.Visibility,
+		&i.Slug,
+		&i.HashedPassword,
+		&i.BurnAfterReading,
+	)
+	return i, err
+}
+
+const getSnippetBySlug = `-- name: GetSnippetBySlug :one
+SELECT snippets.id, snippets.title, snippets.content, snippets.created_at, snippets.expires, snippets.user_id, snippets.language, snippets.format, snippets.updated_at, snippets.visibility, snippets.slug, snippets.hashed_password, snippets.burn_after_reading, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.slug = $1
+  AND (snippets.visibility <> 'private' OR snippets.user_id = $2)
+`
+
+type GetSnippetBySlugParams struct {
+	Slug     string `json:"slug"`
+	ViewerID int32  `json:"viewer_id"`
+}
+
+type GetSnippetBySlugRow struct {
+	ID               int32          `json:"id"`
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Expires          *time.Time     `json:"expires"`
+	UserID           int32          `json:"user_id"`
+	Language         string         `json:"language"`
+	Format           string         `json:"format"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Visibility       string         `json:"visibility"`
+	Slug             string         `json:"slug"`
+	HashedPassword   sql.NullString `json:"-"`
+	BurnAfterReading bool           `json:"burn_after_reading"`
+	Author           string         `json:"author"`
+}
+
+// Private snippets are only returned to their owner, the viewer ID is 0 for
+// anonymous users.
+func (q *Queries) GetSnippetBySlug(ctx context.Context, arg GetSnippetBySlugParams) (GetSnippetBySlugRow, error) {
+	row := q.db.QueryRowContext(ctx, getSnippetBySlug, arg.Slug, arg.ViewerID)
+	var i GetSnippetBySlugRow
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Content,
+		&i.CreatedAt,
+		&i.Expires,
+		&i.UserID,
+		&i.Language,
+		&i.Format,
+		&i.UpdatedAt,
+		&i.Visibility,
+		&i.Slug,
+		&i.HashedPassword,
+		&i.BurnAfterReading,
+		&i.Author,
+	)
+	return i, err
+}
+
+const getSnippetForBurn = `-- name: GetSnippetForBurn :one
+SELECT snippets.id, snippets.title, snippets.content, snippets.created_at, snippets.expires, snippets.user_id, snippets.language, snippets.format, snippets.updated_at, snippets.visibility, snippets.slug, snippets.hashed_password, snippets.burn_after_reading, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.id = $1
+    FOR UPDATE OF snippets
+`
+
+type GetSnippetForBurnRow struct {
+	ID               int32          `json:"id"`
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Expires          *time.Time     `json:"expires"`
+	UserID           int32          `json:"user_id"`
+	Language         string         `json:"language"`
+	Format           string         `json:"format"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Visibility       string         `json:"visibility"`
+	Slug             string         `json:"slug"`
+	HashedPassword   sql.NullString `json:"-"`
+	BurnAfterReading bool           `json:"burn_after_reading"`
+	Author           string         `json:"author"`
+}
+
+// The row stays locked until the end of the transaction, so a concurrent
+// reader waits and then finds the snippet deleted.
+func (q *Queries) GetSnippetForBurn(ctx context.Context, id int32) (GetSnippetForBurnRow, error) {
+	row := q.db.QueryRowContext(ctx, getSnippetForBurn, id)
+	var i GetSnippetForBurnRow
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Content,
+		&i.CreatedAt,
+		&i.Expires,
+		&i.UserID,
+		&i.Language,
+		&i.Format,
+		&i.UpdatedAt,
+		&i.Visibility,
+		&i.Slug,
+		&i.HashedPassword,
+		&i.BurnAfterReading,
+		&i.Author,
+	)
+	return i, err
+}
+
+const getSnippetNotExpired = `-- name: GetSnippetNotExpired :one
+SELECT snippets.id, snippets.title, snippets.content, snippets.created_at, snippets.expires, snippets.user_id, snippets.language, snippets.format, snippets.updated_at, snippets.visibility, snippets.slug, snippets.hashed_password, snippets.burn_after_reading, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.id = $1
+  AND (snippets.visibility = 'public' OR snippets.user_id = $2)
+`
+
+type GetSnippetNotExpiredParams struct {
+	ID       int32 `json:"id"`
+	ViewerID int32 `json:"viewer_id"`
+}
+
+type GetSnippetNotExpiredRow struct {
+	ID               int32          `json:"id"`
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Expires          *time.Time     `json:"expires"`
+	UserID           int32          `json:"user_id"`
+	Language         string         `json:"language"`
+	Format           string         `json:"format"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Visibility       string         `json:"visibility"`
+	Slug             string         `json:"slug"`
+	HashedPassword   sql.NullString `json:"-"`
+	BurnAfterReading bool           `json:"burn_after_reading"`
+	Author           string         `json:"author"`
+}
+
+// IDs can be counted, so only public snippets are returned by ID, except to
+// their owner. The viewer ID is 0 for anonymous users.
+func (q *Queries) GetSnippetNotExpired(ctx context.Context, arg GetSnippetNotExpiredParams) (GetSnippetNotExpiredRow, error) {
+	row := q.db.QueryRowContext(ctx, getSnippetNotExpired, arg.ID, arg.ViewerID)
+	var i GetSnippetNotExpiredRow
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Content,
+		&i.CreatedAt,
+		&i.Expires,
+		&i.UserID,
+		&i.Language,
+		&i.Format,
+		&i.UpdatedAt,
+		&i.Visibility,
+		&i.Slug,
+		&i.HashedPassword,
+		&i.BurnAfterReading,
+		&i.Author,
 	)
 	return i, err
 }
 
-const getTenLatestSnippets = `-- name: GetTenLatestSnippets :many
-SELECT id, title, content, created_at, expires
+const listSnippetsAfter = `-- name: ListSnippetsAfter :many
+SELECT snippets.id, snippets.title, snippets.content, snippets.created_at, snippets.expires, snippets.user_id, snippets.language, snippets.format, snippets.updated_at, snippets.visibility, snippets.slug, snippets.hashed_password, snippets.burn_after_reading, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.visibility = 'public'
+  AND snippets.id > $1
+ORDER BY snippets.id ASC LIMIT $2
+`
+
+type ListSnippetsAfterParams struct {
+	After    int32 `json:"after"`
+	PageSize int32 `json:"page_size"`
+}
+
+type ListSnippetsAfterRow struct {
+	ID               int32          `json:"id"`
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Expires          *time.Time     `json:"expires"`
+	UserID           int32          `json:"user_id"`
+	Language         string         `json:"language"`
+	Format           string         `json:"format"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Visibility       string         `json:"visibility"`
+	Slug             string         `json:"slug"`
+	HashedPassword   sql.NullString `json:"-"`
+	BurnAfterReading bool           `json:"burn_after_reading"`
+	Author           string         `json:"author"`
+}
+
+func (q *Queries) ListSnippetsAfter(ctx context.Context, arg ListSnippetsAfterParams) ([]ListSnippetsAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSnippetsAfter, arg.After, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSnippetsAfterRow{}
+	for rows.Next() {
+		var i ListSnippetsAfterRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Content,
+			&i.CreatedAt,
+			&i.Expires,
+			&i.UserID,
+			&i.Language,
+			&i.Format,
+			&i.UpdatedAt,
+			&i.Visibility,
+			&i.Slug,
+			&i.HashedPassword,
+			&i.BurnAfterReading,
+			&i.Author,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSnippetsBefore = `-- name: ListSnippetsBefore :many
+SELECT snippets.id, snippets.title, snippets.content, snippets.created_at, snippets.expires, snippets.user_id, snippets.language, snippets.format, snippets.updated_at, snippets.visibility, snippets.slug, snippets.hashed_password, snippets.burn_after_reading, users.name AS author
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.visibility = 'public'
+  AND snippets.id < $1
+ORDER BY snippets.id DESC LIMIT $2
+`
+
+type ListSnippetsBeforeParams struct {
+	Before   int32 `json:"before"`
+	PageSize int32 `json:"page_size"`
+}
+
+type ListSnippetsBeforeRow struct {
+	ID               int32          `json:"id"`
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Expires          *time.Time     `json:"expires"`
+	UserID           int32          `json:"user_id"`
+	Language         string         `json:"language"`
+	Format           string         `json:"format"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	Visibility       string         `json:"visibility"`
+	Slug             string         `json:"slug"`
+	HashedPassword   sql.NullString `json:"-"`
+	BurnAfterReading bool           `json:"burn_after_reading"`
+	Author           string         `json:"author"`
+}
+
+func (q *Queries) ListSnippetsBefore(ctx context.Context, arg ListSnippetsBeforeParams) ([]ListSnippetsBeforeRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSnippetsBefore, arg.Before, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSnippetsBeforeRow{}
+	for rows.Next() {
+		var i ListSnippetsBeforeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Content,
+			&i.CreatedAt,
+			&i.Expires,
+			&i.UserID,
+			&i.Language,
+			&i.Format,
+			&i.UpdatedAt,
+			&i.Visibility,
+			&i.Slug,
+			&i.HashedPassword,
+			&i.BurnAfterReading,
+			&i.Author,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSnippetsByUser = `-- name: ListSnippetsByUser :many
+SELECT id, title, content, created_at, expires, user_id, language, format, updated_at, visibility, slug, hashed_password, burn_after_reading
 FROM snippets
-WHERE expires > CURRENT_TIMESTAMP
-ORDER BY id DESC LIMIT 10
+WHERE user_id = $1
+ORDER BY id DESC LIMIT $2 OFFSET $3
 `
 
-func (q *Queries) GetTenLatestSnippets(ctx context.Context) ([]Snippet, error) {
-	rows, err := q.db.QueryContext(ctx, getTenLatestSnippets)
+type ListSnippetsByUserParams struct {
+	UserID int32 `json:"user_id"`
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListSnippetsByUser(ctx context.Context, arg ListSnippetsByUserParams) ([]Snippet, error) {
+	rows, err := q.db.QueryContext(ctx, listSnippetsByUser, arg.UserID, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +417,87 @@ func (q *Queries) GetTenLatestSnippets(ctx context.Context) ([]Snippet, error) {
 			&i.Content,
 			&i.CreatedAt,
 			&i.Expires,
+			&i.UserID,
+			&i.Language,
+			&i.Format,
+			&i.UpdatedAt,
+			&i.Visibility,
+			&i.Slug,
+			&i.HashedPassword,
+			&i.BurnAfterReading,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchSnippets = `-- name: SearchSnippets :many
+SELECT snippets.id,
+       snippets.slug,
+       snippets.title,
+       snippets.created_at,
+       users.name AS author,
+       ts_headline('english', snippets.content, websearch_to_tsquery('english', $1),
+                   'StartSel=' || CHR(2) || ', StopSel=' || CHR(3) ||
+                   ', MaxFragments=2, MaxWords=20, MinWords=8')::text AS headline
+FROM snippets
+         JOIN users ON users.id = snippets.user_id
+WHERE (snippets.expires IS NULL OR snippets.expires > CURRENT_TIMESTAMP)
+  AND snippets.visibility = 'public'
+  AND snippets.hashed_password IS NULL
+  AND NOT snippets.burn_after_reading
+  AND (setweight(to_tsvector('english', snippets.title), 'A') ||
+       setweight(to_tsvector('english', snippets.content), 'B')) @@ websearch_to_tsquery('english', $1)
+ORDER BY ts_rank(setweight(to_tsvector('english', snippets.title), 'A') ||
+                 setweight(to_tsvector('english', snippets.content), 'B'),
+                 websearch_to_tsquery('english', $1)) DESC,
+         snippets.id DESC
+LIMIT $2 OFFSET $3
+`
+
+type SearchSnippetsParams struct {
+	Query      string `json:"query"`
+	PageSize   int32  `json:"page_size"`
+	PageOffset int32  `json:"page_offset"`
+}
+
+type SearchSnippetsRow struct {
+	ID        int32     `json:"id"`
+	Slug      string    `json:"slug"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	Author    string    `json:"author"`
+	Headline  string    `json:"headline"`
+}
+
+// The headline marks the matched words with the STX and ETX control
+// characters, which can't be confused with HTML in the content. Snippets
+// protected by a password or deleted once read are left out, since their
+// content is matched.
+func (q *Queries) SearchSnippets(ctx context.Context, arg SearchSnippetsParams) ([]SearchSnippetsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchSnippets, arg.Query, arg.PageSize, arg.PageOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchSnippetsRow{}
+	for rows.Next() {
+		var i SearchSnippetsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.CreatedAt,
+			&i.Author,
+			&i.Headline,
 		); err != nil {
 			return nil, err
 		}
@@ -82,3 +511,44 @@ func (q *Queries) GetTenLatestSnippets(ctx context.Context) ([]Snippet, error) {
 	}
 	return items, nil
 }
+
+const updateSnippet = `-- name: UpdateSnippet :exec
+UPDATE snippets
+SET title              = $1,
+    content            = $2,
+    language           = $3,
+    format             = $4,
+    visibility         = $5,
+    hashed_password    = $6,
+    burn_after_reading = $7,
+    expires            = $8,
+    updated_at         = CURRENT_TIMESTAMP
+WHERE id = $9
+`
+
+type UpdateSnippetParams struct {
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	Language         string         `json:"language"`
+	Format           string         `json:"format"`
+	Visibility       string         `json:"visibility"`
+	HashedPassword   sql.NullString `json:"-"`
+	BurnAfterReading bool           `json:"burn_after_reading"`
+	Expires          *time.Time     `json:"expires"`
+	ID               int32          `json:"id"`
+}
+
+func (q *Queries) UpdateSnippet(ctx context.Context, arg UpdateSnippetParams) error {
+	_, err := q.db.ExecContext(ctx, updateSnippet,
+		arg.Title,
+		arg.Content,
+		arg.Language,
+		arg.Format,
+		arg.Visibility,
+		arg.HashedPassword,
+		arg.BurnAfterReading,
+		arg.Expires,
+		arg.ID,
+	)
+	return err
+}
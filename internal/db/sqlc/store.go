@@ -1,15 +1,65 @@
 package sqlc
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
 
-type Store struct {
+// Store provides all the queries, and the transactions which run several of them.
+type Store interface {
+	Querier
+	BurnSnippetTx(ctx context.Context, id int32) (GetSnippetForBurnRow, error)
+}
+
+// SQLStore is the Store of a PostgreSQL database.
+type SQLStore struct {
 	*Queries
 	db *sql.DB
 }
 
-func NewStore(db *sql.DB) *Store {
-	return &Store{
+func NewStore(db *sql.DB) *SQLStore {
+	return &SQLStore{
 		Queries: New(db),
 		db:      db,
 	}
 }
+
+// ExecTx runs fn with the queries of a new transaction, which is committed if
+// fn returns nil and rolled back otherwise.
+func (store *SQLStore) ExecTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(store.WithTx(tx))
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rollback err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// BurnSnippetTx returns the snippet which has not expired and deletes it, in
+// the same transaction. Of several concurrent calls for a snippet, only one
+// returns it and the others get sql.ErrNoRows.
+func (store *SQLStore) BurnSnippetTx(ctx context.Context, id int32) (GetSnippetForBurnRow, error) {
+	var snippet GetSnippetForBurnRow
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		snippet, err = q.GetSnippetForBurn(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return q.DeleteSnippet(ctx, id)
+	})
+
+	return snippet, err
+}
@@ -3,6 +3,7 @@ package validator
 import (
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -68,8 +69,63 @@ func IsIntInList(value int, list ...int) bool {
 	return false
 }
 
+// IsIntInRange returns true if a value is between min and max, both included.
+func IsIntInRange(value, min, max int) bool {
+	return value >= min && value <= max
+}
+
+// IsStringInList returns true if a value is in a list of permitted strings.
+func IsStringInList(value string, list ...string) bool {
+	for i := range list {
+		if value == list[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
 // IsMatchRegex returns true if a value matches a provided compiled regular
 // expression pattern.
 func IsMatchRegex(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// IsTimeZone returns true if a value is the name of a time zone in the IANA
+// database, like "Europe/Paris" or "UTC". The server's "Local" zone isn't one.
+func IsTimeZone(value string) bool {
+	if value == "" || value == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(value)
+	return err == nil
+}
+
+// LocalDateTimeLayout is the format of a date-time without a UTC offset, like
+// the value of an HTML datetime-local input.
+const LocalDateTimeLayout = "2006-01-02T15:04"
+
+// ParseDateTime parses an RFC 3339 date-time, or a LocalDateTimeLayout one in
+// the given time zone. ok is false if the value is in neither format, or if
+// it needs an unknown time zone.
+func ParseDateTime(value, timeZone string) (t time.Time, ok bool) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, true
+	}
+
+	if !IsTimeZone(timeZone) {
+		return time.Time{}, false
+	}
+
+	loc, _ := time.LoadLocation(timeZone)
+
+	t, err = time.ParseInLocation(LocalDateTimeLayout, value, loc)
+	return t, err == nil
+}
+
+// IsTimeInRange returns true if a time is after min and not after max.
+func IsTimeInRange(value, min, max time.Time) bool {
+	return value.After(min) && !value.After(max)
+}
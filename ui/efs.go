@@ -0,0 +1,10 @@
+// Package ui holds the HTML templates and static files of the application,
+// which are embedded into the binary so it can run from any directory.
+package ui
+
+import "embed"
+
+// Files contains the "html" and "static" directories.
+//
+//go:embed "html" "static"
+var Files embed.FS
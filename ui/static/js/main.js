@@ -5,4 +5,10 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
+}
+
+// The exact expiry of a snippet is in the time zone of the browser, unless another one is typed.
+var timeZone = document.querySelector("input[name='time_zone']");
+if (timeZone && timeZone.value === "" && window.Intl) {
+	timeZone.value = Intl.DateTimeFormat().resolvedOptions().timeZone || "";
 }
\ No newline at end of file